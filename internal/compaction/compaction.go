@@ -0,0 +1,81 @@
+package compaction
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/thrawn01/lsm-go/internal/iterator"
+	"github.com/thrawn01/lsm-go/internal/sstable"
+	"github.com/thrawn01/lsm-go/internal/sstable/types"
+)
+
+// ErrNoMergeOperator is returned when compacting a merge operand without a Config.MergeOperator
+var ErrNoMergeOperator = iterator.ErrNoMergeOperator
+
+// Iterator is a source of entries in ascending key order, such as sstable.Iterator
+type Iterator = iterator.Source
+
+type Config struct {
+	// SSTable is the config used to encode the compacted SSTable
+	SSTable sstable.Config
+
+	// Bottommost is true if no older SSTables exist which may contain the keys in the
+	// compacted SSTable. Tombstones and expired values are only dropped when compacting
+	// into the bottommost SSTable, else they are written as tombstones such that they
+	// continue to shadow older values of the key.
+	Bottommost bool
+
+	// Now returns the time used to decide if a value has expired. Defaults to time.Now
+	Now func() time.Time
+
+	// MergeOperator is used to collapse merge operands into a single value
+	MergeOperator types.MergeOperator
+}
+
+// Compact merges the entries from the sources into a single SSTable. Sources must be
+// ordered from newest to oldest, when more than one source contains the same key, only
+// the entry from the newest source is kept. Expired values are permanently removed, such
+// that they are never returned by a reader, even if the expiration time is changed.
+//
+// Merge operands are combined with the older value of the key, if the older value is not
+// in any of the sources and the compaction is not Config.Bottommost, the operands are
+// combined into a single merge operand.
+func Compact(conf Config, sources ...Iterator) (*sstable.Table, error) {
+	if conf.Now == nil {
+		conf.Now = time.Now
+	}
+	now := conf.Now()
+
+	// Tombstones and expired values are only dropped from the bottommost SSTable
+	merged := iterator.NewMergeIterator(iterator.MergeConfig{
+		DropTombstones: conf.Bottommost,
+		Bottommost:     conf.Bottommost,
+		MergeOperator:  conf.MergeOperator,
+		Now:            func() time.Time { return now },
+	}, sources...)
+
+	builder := sstable.NewBuilder(conf.SSTable)
+	for {
+		kv, ok := merged.NextEntry()
+		if !ok {
+			break
+		}
+		if kv.Value.IsTombstone || kv.Value.IsExpired(now) {
+			kv.Value = types.Value{IsTombstone: true}
+		}
+		if err := builder.AddEntry(kv); err != nil {
+			return nil, fmt.Errorf("while adding key to SSTable: %w", err)
+		}
+	}
+
+	if err := merged.Err(); err != nil {
+		return nil, err
+	}
+
+	table := builder.Build()
+	if table == nil {
+		return nil, errors.New("while encoding SSTable: sstable.Builder.Build() failed")
+	}
+	return table, nil
+}
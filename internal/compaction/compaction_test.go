@@ -0,0 +1,182 @@
+package compaction_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/lsm-go/internal/compaction"
+	"github.com/thrawn01/lsm-go/internal/compress"
+	"github.com/thrawn01/lsm-go/internal/iterator"
+	"github.com/thrawn01/lsm-go/internal/sstable"
+	"github.com/thrawn01/lsm-go/internal/sstable/types"
+)
+
+var testSSTableConfig = sstable.Config{
+	BlockSize:        4096,
+	MinFilterKeys:    10,
+	FilterBitsPerKey: 10,
+	Compression:      compress.CodecNone,
+}
+
+type sliceBlob struct {
+	data []byte
+}
+
+func (b *sliceBlob) Len() (uint64, error)                      { return uint64(len(b.data)), nil }
+func (b *sliceBlob) ReadRange(r sstable.Range) ([]byte, error) { return b.data[r.Start:r.End], nil }
+func (b *sliceBlob) Read() ([]byte, error)                     { return b.data, nil }
+func (b *sliceBlob) Id() string                                { return "test" }
+
+// readTable decodes every entry in the SSTable
+func readTable(t *testing.T, table *sstable.Table) []types.KeyValue {
+	t.Helper()
+	r, err := sstable.NewReader(&sstable.Decoder{Config: testSSTableConfig}, &sliceBlob{data: table.Data})
+	require.NoError(t, err)
+
+	var entries []types.KeyValue
+	iter := r.Iterator()
+	for {
+		kv, ok := iter.NextEntry()
+		if !ok {
+			break
+		}
+		entries = append(entries, kv)
+	}
+	require.NoError(t, iter.Err())
+	return entries
+}
+
+func TestCompact(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute).UnixMilli()
+	future := now.Add(time.Hour).UnixMilli()
+
+	newer := []types.KeyValue{
+		{Key: []byte("key1"), Value: types.Value{Value: []byte("value1-new")}, Seq: 10},
+		{Key: []byte("key2"), Value: types.Value{IsTombstone: true}, Seq: 11},
+		{Key: []byte("key4"), Value: types.Value{Value: []byte("value4"), ExpireAt: past}, Seq: 12},
+	}
+	older := []types.KeyValue{
+		{Key: []byte("key1"), Value: types.Value{Value: []byte("value1")}, Seq: 1},
+		{Key: []byte("key2"), Value: types.Value{Value: []byte("value2")}, Seq: 2},
+		{Key: []byte("key3"), Value: types.Value{Value: []byte("value3"), ExpireAt: future}, Seq: 3},
+		{Key: []byte("key4"), Value: types.Value{Value: []byte("value4-old")}, Seq: 4},
+		{Key: []byte("key5"), Value: types.Value{Value: []byte("value5"), ExpireAt: past}, Seq: 5},
+	}
+
+	for _, tt := range []struct {
+		name       string
+		bottommost bool
+		expected   []types.KeyValue
+	}{
+		{
+			name:       "Bottommost",
+			bottommost: true,
+			expected: []types.KeyValue{
+				{Key: []byte("key1"), Value: types.Value{Value: []byte("value1-new")}, Seq: 10},
+				{Key: []byte("key3"), Value: types.Value{Value: []byte("value3"), ExpireAt: future}, Seq: 3},
+			},
+		},
+		{
+			name: "NotBottommost",
+			expected: []types.KeyValue{
+				{Key: []byte("key1"), Value: types.Value{Value: []byte("value1-new")}, Seq: 10},
+				{Key: []byte("key2"), Value: types.Value{IsTombstone: true}, Seq: 11},
+				{Key: []byte("key3"), Value: types.Value{Value: []byte("value3"), ExpireAt: future}, Seq: 3},
+				{Key: []byte("key4"), Value: types.Value{IsTombstone: true}, Seq: 12},
+				{Key: []byte("key5"), Value: types.Value{IsTombstone: true}, Seq: 5},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			table, err := compaction.Compact(compaction.Config{
+				SSTable:    testSSTableConfig,
+				Bottommost: tt.bottommost,
+				Now:        func() time.Time { return now },
+			}, iterator.NewSliceSource(newer...), iterator.NewSliceSource(older...))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, readTable(t, table))
+		})
+	}
+}
+
+func TestCompactEverythingExpired(t *testing.T) {
+	past := time.Now().Add(-time.Minute).UnixMilli()
+	table, err := compaction.Compact(compaction.Config{SSTable: testSSTableConfig, Bottommost: true},
+		iterator.NewSliceSource(types.KeyValue{Key: []byte("key1"), Value: types.Value{Value: []byte("value1"), ExpireAt: past}}))
+	require.NoError(t, err)
+	assert.Empty(t, readTable(t, table))
+}
+
+// appendOperator appends each operand to the existing value separated by a comma
+type appendOperator struct{}
+
+func (appendOperator) Merge(_, existing, operand []byte) ([]byte, error) {
+	if existing == nil {
+		return append([]byte(nil), operand...), nil
+	}
+	return append(append(append([]byte(nil), existing...), ','), operand...), nil
+}
+
+func TestCompactMerge(t *testing.T) {
+	merge := func(key, operand string, seq uint64) types.KeyValue {
+		return types.KeyValue{Key: []byte(key), Value: types.Value{Value: []byte(operand), IsMerge: true}, Seq: seq}
+	}
+	newest := []types.KeyValue{
+		merge("key1", "c", 30),
+		merge("key2", "b", 31),
+		merge("key3", "b", 32),
+		merge("key4", "b", 33),
+	}
+	newer := []types.KeyValue{
+		merge("key1", "b", 20),
+		{Key: []byte("key2"), Value: types.Value{IsTombstone: true}, Seq: 21},
+		merge("key3", "a", 22),
+	}
+	older := []types.KeyValue{
+		{Key: []byte("key1"), Value: types.Value{Value: []byte("a")}, Seq: 10},
+		{Key: []byte("key2"), Value: types.Value{Value: []byte("a")}, Seq: 11},
+	}
+
+	for _, tt := range []struct {
+		name       string
+		bottommost bool
+		expected   []types.KeyValue
+	}{
+		{
+			name:       "Bottommost",
+			bottommost: true,
+			expected: []types.KeyValue{
+				{Key: []byte("key1"), Value: types.Value{Value: []byte("a,b,c")}, Seq: 30},
+				{Key: []byte("key2"), Value: types.Value{Value: []byte("b")}, Seq: 31},
+				{Key: []byte("key3"), Value: types.Value{Value: []byte("a,b")}, Seq: 32},
+				{Key: []byte("key4"), Value: types.Value{Value: []byte("b")}, Seq: 33},
+			},
+		},
+		{
+			// Without an older value the operands are combined into a single operand
+			name: "NotBottommost",
+			expected: []types.KeyValue{
+				{Key: []byte("key1"), Value: types.Value{Value: []byte("a,b,c")}, Seq: 30},
+				{Key: []byte("key2"), Value: types.Value{Value: []byte("b")}, Seq: 31},
+				merge("key3", "a,b", 32),
+				merge("key4", "b", 33),
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			table, err := compaction.Compact(compaction.Config{
+				SSTable:       testSSTableConfig,
+				Bottommost:    tt.bottommost,
+				MergeOperator: appendOperator{},
+			}, iterator.NewSliceSource(newest...), iterator.NewSliceSource(newer...), iterator.NewSliceSource(older...))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, readTable(t, table))
+		})
+	}
+
+	_, err := compaction.Compact(compaction.Config{SSTable: testSSTableConfig}, iterator.NewSliceSource(newest...))
+	assert.ErrorIs(t, err, compaction.ErrNoMergeOperator)
+}
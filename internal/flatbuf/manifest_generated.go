@@ -253,6 +253,9 @@ type SsTableInfoT struct {
 	FilterOffset      uint64            `json:"filter_offset"`
 	FilterLen         uint64            `json:"filter_len"`
 	CompressionFormat CompressionFormat `json:"compression_format"`
+	MinSeq            uint64            `json:"min_seq"`
+	MaxSeq            uint64            `json:"max_seq"`
+	WriterEpoch       uint64            `json:"writer_epoch"`
 }
 
 func (t *SsTableInfoT) Pack(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
@@ -270,6 +273,9 @@ func (t *SsTableInfoT) Pack(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
 	SsTableInfoAddFilterOffset(builder, t.FilterOffset)
 	SsTableInfoAddFilterLen(builder, t.FilterLen)
 	SsTableInfoAddCompressionFormat(builder, t.CompressionFormat)
+	SsTableInfoAddMinSeq(builder, t.MinSeq)
+	SsTableInfoAddMaxSeq(builder, t.MaxSeq)
+	SsTableInfoAddWriterEpoch(builder, t.WriterEpoch)
 	return SsTableInfoEnd(builder)
 }
 
@@ -280,6 +286,9 @@ func (rcv *SsTableInfo) UnPackTo(t *SsTableInfoT) {
 	t.FilterOffset = rcv.FilterOffset()
 	t.FilterLen = rcv.FilterLen()
 	t.CompressionFormat = rcv.CompressionFormat()
+	t.MinSeq = rcv.MinSeq()
+	t.MaxSeq = rcv.MaxSeq()
+	t.WriterEpoch = rcv.WriterEpoch()
 }
 
 func (rcv *SsTableInfo) UnPack() *SsTableInfoT {
@@ -420,8 +429,44 @@ func (rcv *SsTableInfo) MutateCompressionFormat(n CompressionFormat) bool {
 	return rcv._tab.MutateInt8Slot(14, int8(n))
 }
 
+func (rcv *SsTableInfo) MinSeq() uint64 {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(16))
+	if o != 0 {
+		return rcv._tab.GetUint64(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *SsTableInfo) MutateMinSeq(n uint64) bool {
+	return rcv._tab.MutateUint64Slot(16, n)
+}
+
+func (rcv *SsTableInfo) MaxSeq() uint64 {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(18))
+	if o != 0 {
+		return rcv._tab.GetUint64(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *SsTableInfo) MutateMaxSeq(n uint64) bool {
+	return rcv._tab.MutateUint64Slot(18, n)
+}
+
+func (rcv *SsTableInfo) WriterEpoch() uint64 {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(20))
+	if o != 0 {
+		return rcv._tab.GetUint64(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *SsTableInfo) MutateWriterEpoch(n uint64) bool {
+	return rcv._tab.MutateUint64Slot(20, n)
+}
+
 func SsTableInfoStart(builder *flatbuffers.Builder) {
-	builder.StartObject(6)
+	builder.StartObject(9)
 }
 func SsTableInfoAddFirstKey(builder *flatbuffers.Builder, firstKey flatbuffers.UOffsetT) {
 	builder.PrependUOffsetTSlot(0, flatbuffers.UOffsetT(firstKey), 0)
@@ -444,6 +489,15 @@ func SsTableInfoAddFilterLen(builder *flatbuffers.Builder, filterLen uint64) {
 func SsTableInfoAddCompressionFormat(builder *flatbuffers.Builder, compressionFormat CompressionFormat) {
 	builder.PrependInt8Slot(5, int8(compressionFormat), 0)
 }
+func SsTableInfoAddMinSeq(builder *flatbuffers.Builder, minSeq uint64) {
+	builder.PrependUint64Slot(6, minSeq, 0)
+}
+func SsTableInfoAddMaxSeq(builder *flatbuffers.Builder, maxSeq uint64) {
+	builder.PrependUint64Slot(7, maxSeq, 0)
+}
+func SsTableInfoAddWriterEpoch(builder *flatbuffers.Builder, writerEpoch uint64) {
+	builder.PrependUint64Slot(8, writerEpoch, 0)
+}
 func SsTableInfoEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
 	return builder.EndObject()
 }
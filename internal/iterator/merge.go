@@ -0,0 +1,263 @@
+package iterator
+
+import (
+	"bytes"
+	"container/heap"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/thrawn01/lsm-go/internal/sstable/types"
+)
+
+// ErrNoMergeOperator is returned by MergeIterator.Err when a merge operand is found
+// without a MergeConfig.MergeOperator
+var ErrNoMergeOperator = errors.New("merge operand found but no MergeOperator was configured")
+
+// Source is a source of entries in ascending key order, such as sstable.Iterator or
+// block.Iterator. If the source also has an `Err() error` method, it is checked when the
+// source returns false to detect a source which stopped because of an error.
+type Source interface {
+	NextEntry() (types.KeyValue, bool)
+}
+
+// SliceSource is a Source of entries held in memory, the entries must be in ascending key order
+type SliceSource struct {
+	entries []types.KeyValue
+}
+
+// NewSliceSource returns a SliceSource which returns the entries in the order provided
+func NewSliceSource(entries ...types.KeyValue) *SliceSource {
+	return &SliceSource{entries: entries}
+}
+
+func (s *SliceSource) NextEntry() (types.KeyValue, bool) {
+	if len(s.entries) == 0 {
+		return types.KeyValue{}, false
+	}
+	kv := s.entries[0]
+	s.entries = s.entries[1:]
+	return kv, true
+}
+
+type MergeConfig struct {
+	// DropTombstones if true skips every key where the newest entry is a tombstone or an
+	// expired value, such that NextEntry only returns live values and merge operands.
+	DropTombstones bool
+
+	// Bottommost is true if no older sources exist which may contain the keys, such that
+	// NextEntry applies merge operands without an older value of the key as if the key has
+	// no value. Else the operands are combined into a single merge operand, which must be
+	// resolved with the value of the key from an older source.
+	Bottommost bool
+
+	// MergeOperator combines merge operands with the older values of the key. If nil,
+	// the iteration stops with ErrNoMergeOperator once a merge operand is found.
+	MergeOperator types.MergeOperator
+
+	// Now returns the time used to decide if a value has expired. Defaults to time.Now
+	Now func() time.Time
+}
+
+// MergeIterator merges the entries of many sources into a single source in ascending key
+// order using a heap, such that each call reads the next entry from only one source.
+// Sources are ordered from newest to oldest, when more than one source contains the same
+// key, the entry from the newest source wins.
+type MergeIterator struct {
+	conf    MergeConfig
+	now     time.Time
+	sources []Source
+	heads   mergeHeap
+	err     error
+}
+
+// NewMergeIterator returns a MergeIterator over the sources, which must be ordered from
+// newest to oldest.
+func NewMergeIterator(conf MergeConfig, sources ...Source) *MergeIterator {
+	if conf.Now == nil {
+		conf.Now = time.Now
+	}
+	iter := &MergeIterator{
+		conf:    conf,
+		now:     conf.Now(),
+		sources: sources,
+		heads:   make(mergeHeap, 0, len(sources)),
+	}
+	for i := range sources {
+		if h, ok := iter.advance(i); ok {
+			iter.heads = append(iter.heads, h)
+		}
+	}
+	heap.Init(&iter.heads)
+	return iter
+}
+
+// Next returns the newest value of the next key, skipping keys where the newest entry is a
+// tombstone or an expired value. Merge operands are combined with the older values of the
+// key, if the sources do not contain a value for the key, the operands are applied as if
+// the key has no value.
+func (iter *MergeIterator) Next() (types.KV, bool) {
+	for {
+		entries, ok := iter.NextKey()
+		if !ok {
+			return types.KV{}, false
+		}
+		kv, ok := iter.resolve(entries, true)
+		if !ok {
+			return types.KV{}, false
+		}
+		if kv.Value.IsTombstone || kv.Value.IsExpired(iter.now) {
+			continue
+		}
+		return types.KV{
+			Key:   kv.Key,
+			Value: kv.Value.Value,
+		}, true
+	}
+}
+
+// NextEntry returns the newest entry of the next key, including tombstones and expired
+// values unless MergeConfig.DropTombstones is true. Merge operands are combined with the
+// older values of the key as described by MergeConfig.Bottommost.
+func (iter *MergeIterator) NextEntry() (types.KeyValue, bool) {
+	for {
+		entries, ok := iter.NextKey()
+		if !ok {
+			return types.KeyValue{}, false
+		}
+		kv, ok := iter.resolve(entries, iter.conf.Bottommost)
+		if !ok {
+			return types.KeyValue{}, false
+		}
+		if iter.conf.DropTombstones && (kv.Value.IsTombstone || kv.Value.IsExpired(iter.now)) {
+			continue
+		}
+		return kv, true
+	}
+}
+
+// NextKey returns every entry of the next key ordered from the newest source to the oldest.
+// Merge operands are not combined and tombstones are never dropped by NextKey.
+func (iter *MergeIterator) NextKey() ([]types.KeyValue, bool) {
+	if iter.err != nil || len(iter.heads) == 0 {
+		return nil, false
+	}
+
+	// The heap orders entries of the same key from the newest source to the oldest
+	first := heap.Pop(&iter.heads).(head)
+	entries := []types.KeyValue{first.kv}
+	iter.push(first.source)
+	for len(iter.heads) != 0 && bytes.Equal(iter.heads[0].kv.Key, first.kv.Key) {
+		h := heap.Pop(&iter.heads).(head)
+		entries = append(entries, h.kv)
+		iter.push(h.source)
+	}
+	if iter.err != nil {
+		return nil, false
+	}
+	return entries, true
+}
+
+// Err returns the error of the source or the MergeConfig.MergeOperator which stopped the
+// iteration, if any
+func (iter *MergeIterator) Err() error {
+	return iter.err
+}
+
+// resolve returns the newest of the entries, which are ordered from newest to oldest, with
+// the merge operands at the start of entries applied to the first older entry which is not
+// a merge operand. A tombstone or expired value is treated as if the key has no value. If
+// every entry is a merge operand and resolveAll is false, the operands are combined into a
+// single merge operand. Returns false if the merge failed, in which case the error is saved.
+func (iter *MergeIterator) resolve(entries []types.KeyValue, resolveAll bool) (types.KeyValue, bool) {
+	kv := entries[0]
+	if !kv.Value.IsMerge || kv.Value.IsExpired(iter.now) {
+		return kv, true
+	}
+	if iter.conf.MergeOperator == nil {
+		iter.err = fmt.Errorf("while merging key '%s': %w", kv.Key, ErrNoMergeOperator)
+		return types.KeyValue{}, false
+	}
+
+	n := 0
+	for n < len(entries) && entries[n].Value.IsMerge && !entries[n].Value.IsExpired(iter.now) {
+		n++
+	}
+
+	var existing []byte
+	isMerge := false
+	next := n - 1
+	if n < len(entries) {
+		if base := entries[n].Value; !base.IsTombstone && !base.IsExpired(iter.now) {
+			existing = base.Value
+		}
+	} else if !resolveAll {
+		// No value was found, the oldest operand is the base of the combined operand
+		isMerge = true
+		existing = entries[next].Value.Value
+		next--
+	}
+
+	// Apply the operands from oldest to newest
+	for i := next; i >= 0; i-- {
+		var err error
+		if existing, err = iter.conf.MergeOperator.Merge(kv.Key, existing, entries[i].Value.Value); err != nil {
+			iter.err = fmt.Errorf("while merging key '%s': %w", kv.Key, err)
+			return types.KeyValue{}, false
+		}
+	}
+
+	kv.Value = types.Value{Value: existing, IsMerge: isMerge, ExpireAt: kv.Value.ExpireAt}
+	return kv, true
+}
+
+// push reads the next entry from the source onto the heap
+func (iter *MergeIterator) push(source int) {
+	if h, ok := iter.advance(source); ok {
+		heap.Push(&iter.heads, h)
+	}
+}
+
+// advance returns the next entry of the source, returns false if the source has no more
+// entries or stopped because of an error, in which case the error is saved.
+func (iter *MergeIterator) advance(source int) (head, bool) {
+	kv, ok := iter.sources[source].NextEntry()
+	if !ok {
+		if s, ok := iter.sources[source].(interface{ Err() error }); ok && s.Err() != nil {
+			iter.err = fmt.Errorf("while reading merge source %d: %w", source, s.Err())
+		}
+		return head{}, false
+	}
+	return head{kv: kv, source: source}, true
+}
+
+// head is the next entry of a source
+type head struct {
+	kv     types.KeyValue
+	source int
+}
+
+// mergeHeap is a min heap of the next entry of each source, ordered by key then by source,
+// such that the entry of the newest source is first when sources contain the same key.
+type mergeHeap []head
+
+func (h mergeHeap) Len() int { return len(h) }
+
+func (h mergeHeap) Less(i, j int) bool {
+	if c := bytes.Compare(h[i].kv.Key, h[j].kv.Key); c != 0 {
+		return c < 0
+	}
+	return h[i].source < h[j].source
+}
+
+func (h mergeHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *mergeHeap) Push(x any) { *h = append(*h, x.(head)) }
+
+func (h *mergeHeap) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
@@ -0,0 +1,289 @@
+package iterator_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/lsm-go/internal/iterator"
+	"github.com/thrawn01/lsm-go/internal/sstable/types"
+)
+
+func value(key, v string) types.KeyValue {
+	return types.KeyValue{Key: []byte(key), Value: types.Value{Value: []byte(v)}}
+}
+
+func tombstone(key string) types.KeyValue {
+	return types.KeyValue{Key: []byte(key), Value: types.Value{IsTombstone: true}}
+}
+
+// failingSource returns its entries then stops with err
+type failingSource struct {
+	*iterator.SliceSource
+	done bool
+	err  error
+}
+
+func (s *failingSource) NextEntry() (types.KeyValue, bool) {
+	kv, ok := s.SliceSource.NextEntry()
+	s.done = !ok
+	return kv, ok
+}
+
+func (s *failingSource) Err() error {
+	if s.done {
+		return s.err
+	}
+	return nil
+}
+
+func TestMergeIterator(t *testing.T) {
+	newSources := func() []iterator.Source {
+		// Ordered from newest to oldest
+		return []iterator.Source{
+			iterator.NewSliceSource(value("b", "b-new"), tombstone("d")),
+			iterator.NewSliceSource(),
+			iterator.NewSliceSource(value("a", "a-old"), value("b", "b-old"), value("d", "d-old"), value("e", "e-old")),
+			iterator.NewSliceSource(value("c", "c-oldest"), value("e", "e-oldest")),
+		}
+	}
+
+	t.Run("NextEntry", func(t *testing.T) {
+		iter := iterator.NewMergeIterator(iterator.MergeConfig{}, newSources()...)
+		var keys, values []string
+		for {
+			kv, ok := iter.NextEntry()
+			if !ok {
+				break
+			}
+			keys = append(keys, string(kv.Key))
+			values = append(values, string(kv.Value.Value))
+		}
+		require.NoError(t, iter.Err())
+		assert.Equal(t, []string{"a", "b", "c", "d", "e"}, keys)
+		assert.Equal(t, []string{"a-old", "b-new", "c-oldest", "", "e-old"}, values)
+	})
+
+	t.Run("Next", func(t *testing.T) {
+		iter := iterator.NewMergeIterator(iterator.MergeConfig{}, newSources()...)
+		var keys []string
+		for {
+			kv, ok := iter.Next()
+			if !ok {
+				break
+			}
+			keys = append(keys, string(kv.Key))
+		}
+		assert.Equal(t, []string{"a", "b", "c", "e"}, keys)
+	})
+
+	t.Run("NextKey", func(t *testing.T) {
+		iter := iterator.NewMergeIterator(iterator.MergeConfig{}, newSources()...)
+		var versions [][]string
+		for {
+			entries, ok := iter.NextKey()
+			if !ok {
+				break
+			}
+			var v []string
+			for _, e := range entries {
+				v = append(v, string(e.Value.Value))
+			}
+			versions = append(versions, v)
+		}
+		assert.Equal(t, [][]string{
+			{"a-old"},
+			{"b-new", "b-old"},
+			{"c-oldest"},
+			{"", "d-old"},
+			{"e-old", "e-oldest"},
+		}, versions)
+	})
+}
+
+func TestMergeIteratorDropTombstones(t *testing.T) {
+	now := time.Now()
+	expired := types.KeyValue{
+		Key:   []byte("c"),
+		Value: types.Value{Value: []byte("c-expired"), ExpireAt: now.Add(-time.Minute).UnixMilli()},
+	}
+
+	iter := iterator.NewMergeIterator(iterator.MergeConfig{
+		DropTombstones: true,
+		Now:            func() time.Time { return now },
+	},
+		iterator.NewSliceSource(tombstone("a"), value("b", "b-new"), expired),
+		iterator.NewSliceSource(value("a", "a-old"), value("c", "c-old"), value("d", "d-old"), value("e", "e-old")),
+	)
+
+	var keys, values []string
+	for {
+		kv, ok := iter.NextEntry()
+		if !ok {
+			break
+		}
+		keys = append(keys, string(kv.Key))
+		values = append(values, string(kv.Value.Value))
+	}
+	require.NoError(t, iter.Err())
+	// Older values of deleted and expired keys are never returned
+	assert.Equal(t, []string{"b", "d", "e"}, keys)
+	assert.Equal(t, []string{"b-new", "d-old", "e-old"}, values)
+}
+
+// appendOperator appends each operand to the existing value separated by a comma
+type appendOperator struct{}
+
+func (appendOperator) Merge(_, existing, operand []byte) ([]byte, error) {
+	if existing == nil {
+		return append([]byte(nil), operand...), nil
+	}
+	return append(append(append([]byte(nil), existing...), ','), operand...), nil
+}
+
+// failOperator fails every merge
+type failOperator struct{}
+
+func (failOperator) Merge(_, _, _ []byte) ([]byte, error) {
+	return nil, errors.New("merge failed")
+}
+
+func merge(key, operand string) types.KeyValue {
+	return types.KeyValue{Key: []byte(key), Value: types.Value{Value: []byte(operand), IsMerge: true}}
+}
+
+func TestMergeIteratorMerge(t *testing.T) {
+	newSources := func() []iterator.Source {
+		return []iterator.Source{
+			iterator.NewSliceSource(merge("a", "c"), merge("b", "b"), merge("c", "b"), merge("d", "b")),
+			iterator.NewSliceSource(merge("a", "b"), tombstone("b"), merge("c", "a")),
+			iterator.NewSliceSource(value("a", "a"), value("b", "a")),
+		}
+	}
+
+	t.Run("Next", func(t *testing.T) {
+		iter := iterator.NewMergeIterator(iterator.MergeConfig{MergeOperator: appendOperator{}}, newSources()...)
+		var kvs []types.KV
+		for {
+			kv, ok := iter.Next()
+			if !ok {
+				break
+			}
+			kvs = append(kvs, kv)
+		}
+		require.NoError(t, iter.Err())
+		// Operands without an older value are applied as if the key has no value
+		assert.Equal(t, []types.KV{
+			{Key: []byte("a"), Value: []byte("a,b,c")},
+			{Key: []byte("b"), Value: []byte("b")},
+			{Key: []byte("c"), Value: []byte("a,b")},
+			{Key: []byte("d"), Value: []byte("b")},
+		}, kvs)
+	})
+
+	for _, tt := range []struct {
+		name       string
+		bottommost bool
+		expected   []types.KeyValue
+	}{
+		{
+			name:       "Bottommost",
+			bottommost: true,
+			expected: []types.KeyValue{
+				value("a", "a,b,c"),
+				value("b", "b"),
+				value("c", "a,b"),
+				value("d", "b"),
+			},
+		},
+		{
+			// Without an older value the operands are combined into a single operand
+			name: "NotBottommost",
+			expected: []types.KeyValue{
+				value("a", "a,b,c"),
+				value("b", "b"),
+				merge("c", "a,b"),
+				merge("d", "b"),
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			iter := iterator.NewMergeIterator(iterator.MergeConfig{
+				Bottommost:    tt.bottommost,
+				MergeOperator: appendOperator{},
+			}, newSources()...)
+			var entries []types.KeyValue
+			for {
+				kv, ok := iter.NextEntry()
+				if !ok {
+					break
+				}
+				entries = append(entries, kv)
+			}
+			require.NoError(t, iter.Err())
+			assert.Equal(t, tt.expected, entries)
+		})
+	}
+
+	t.Run("Errors", func(t *testing.T) {
+		iter := iterator.NewMergeIterator(iterator.MergeConfig{MergeOperator: failOperator{}}, newSources()...)
+		_, ok := iter.Next()
+		assert.False(t, ok)
+		assert.ErrorContains(t, iter.Err(), "while merging key 'a': merge failed")
+
+		iter = iterator.NewMergeIterator(iterator.MergeConfig{}, newSources()...)
+		_, ok = iter.NextEntry()
+		assert.False(t, ok)
+		assert.ErrorIs(t, iter.Err(), iterator.ErrNoMergeOperator)
+	})
+}
+
+func TestMergeIteratorManySources(t *testing.T) {
+	// Each source contains every key, such that only the newest source is returned
+	var sources []iterator.Source
+	for i := 0; i < 20; i++ {
+		sources = append(sources, iterator.NewSliceSource(
+			value("key1", string(rune('a'+i))),
+			value("key2", string(rune('a'+i))),
+		))
+	}
+
+	iter := iterator.NewMergeIterator(iterator.MergeConfig{}, sources...)
+	for _, key := range []string{"key1", "key2"} {
+		entries, ok := iter.NextKey()
+		require.True(t, ok)
+		assert.Equal(t, []byte(key), entries[0].Key)
+		assert.Equal(t, []byte("a"), entries[0].Value.Value)
+		assert.Len(t, entries, 20)
+	}
+	_, ok := iter.NextKey()
+	assert.False(t, ok)
+
+	// No sources
+	_, ok = iterator.NewMergeIterator(iterator.MergeConfig{}).NextEntry()
+	assert.False(t, ok)
+}
+
+func TestMergeIteratorError(t *testing.T) {
+	failing := &failingSource{
+		SliceSource: iterator.NewSliceSource(value("a", "a-new")),
+		err:         errors.New("read failed"),
+	}
+	iter := iterator.NewMergeIterator(iterator.MergeConfig{},
+		iterator.NewSliceSource(value("b", "b-new")),
+		failing,
+		iterator.NewSliceSource(value("a", "a-old"), value("c", "c-old")),
+	)
+
+	// The error is found while reading the next entry of the failed source
+	_, ok := iter.NextEntry()
+	assert.False(t, ok)
+	require.Error(t, iter.Err())
+	assert.ErrorIs(t, iter.Err(), failing.err)
+	assert.Contains(t, iter.Err().Error(), "while reading merge source 1")
+
+	_, ok = iter.NextEntry()
+	assert.False(t, ok)
+}
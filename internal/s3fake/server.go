@@ -0,0 +1,256 @@
+package s3fake
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server is an in-process stand-in for the subset of the S3 API used by objstore.S3Store.
+// Objects are kept in memory. Requests must carry a SigV4 Authorization header and a
+// payload hash matching the body, but the signature itself is not verified.
+//
+// Use with httptest.NewServer(s3fake.New("bucket"))
+type Server struct {
+	bucket string
+	// MaxKeys is the maximum number of keys returned by each ListObjectsV2 request,
+	// used to exercise pagination. Defaults to 1000
+	MaxKeys int
+
+	mu      sync.Mutex
+	objects map[string]object
+}
+
+type object struct {
+	data     []byte
+	modified time.Time
+}
+
+// New returns a Server with a single empty bucket
+func New(bucket string) *Server {
+	return &Server{
+		bucket:  bucket,
+		objects: make(map[string]object),
+	}
+}
+
+// Object returns the contents of the object with the provided key
+func (s *Server) Object(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, ok := s.objects[key]
+	return obj.data, ok
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=") {
+		writeError(w, http.StatusForbidden, "AccessDenied", "missing or invalid Authorization header")
+		return
+	}
+
+	// Virtual hosted style requests address the bucket in the host
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	bucket, key := path, ""
+	if strings.HasPrefix(r.Host, s.bucket+".") {
+		bucket, key = s.bucket, path
+	} else if i := strings.Index(path, "/"); i != -1 {
+		bucket, key = path[:i], path[i+1:]
+	}
+	if bucket != s.bucket {
+		writeError(w, http.StatusNotFound, "NoSuchBucket", "the specified bucket does not exist")
+		return
+	}
+
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		s.list(w, r)
+	case key == "":
+		writeError(w, http.StatusNotImplemented, "NotImplemented", "bucket operation not supported")
+	case r.Method == http.MethodPut:
+		s.put(w, r, key)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		s.get(w, r, key)
+	case r.Method == http.MethodDelete:
+		s.mu.Lock()
+		delete(s.objects, key)
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "method not allowed")
+	}
+}
+
+func (s *Server) put(w http.ResponseWriter, r *http.Request, key string) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+	sum := sha256.Sum256(data)
+	if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+		writeError(w, http.StatusBadRequest, "XAmzContentSHA256Mismatch",
+			"the provided x-amz-content-sha256 header does not match the body")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.objects[key]; ok && r.Header.Get("If-None-Match") == "*" {
+		writeError(w, http.StatusPreconditionFailed, "PreconditionFailed",
+			"at least one of the pre-conditions you specified did not hold")
+		return
+	}
+	s.objects[key] = object{data: data, modified: time.Now()}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request, key string) {
+	s.mu.Lock()
+	obj, ok := s.objects[key]
+	s.mu.Unlock()
+	data := obj.data
+	if !ok {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writeError(w, http.StatusNotFound, "NoSuchKey", "the specified key does not exist")
+		return
+	}
+
+	status := http.StatusOK
+	if rng := r.Header.Get("Range"); rng != "" {
+		start, end, ok := parseRange(rng, len(data))
+		if !ok {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", len(data)))
+			writeError(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange",
+				"the requested range is not satisfiable")
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end-1, len(data)))
+		data = data[start:end]
+		status = http.StatusPartialContent
+	}
+
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Last-Modified", obj.modified.UTC().Format(http.TimeFormat))
+	w.WriteHeader(status)
+	if r.Method == http.MethodGet {
+		_, _ = w.Write(data)
+	}
+}
+
+// parseRange parses a single `bytes=<first>-<last>` range, returning the range as
+// [start, end). The last byte is clamped to the end of the object, as S3 does.
+func parseRange(rng string, size int) (int, int, bool) {
+	spec, ok := strings.CutPrefix(rng, "bytes=")
+	if !ok {
+		return 0, 0, false
+	}
+	first, last, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, 0, false
+	}
+	start, err := strconv.Atoi(first)
+	if err != nil || start >= size {
+		return 0, 0, false
+	}
+	end := size
+	if last != "" {
+		l, err := strconv.Atoi(last)
+		if err != nil || l < start {
+			return 0, 0, false
+		}
+		end = min(l+1, size)
+	}
+	return start, end, true
+}
+
+type listBucketResult struct {
+	XMLName               xml.Name    `xml:"ListBucketResult"`
+	Name                  string      `xml:"Name"`
+	Prefix                string      `xml:"Prefix"`
+	KeyCount              int         `xml:"KeyCount"`
+	MaxKeys               int         `xml:"MaxKeys"`
+	IsTruncated           bool        `xml:"IsTruncated"`
+	ContinuationToken     string      `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string      `xml:"NextContinuationToken,omitempty"`
+	Contents              []listEntry `xml:"Contents"`
+}
+
+type listEntry struct {
+	Key  string `xml:"Key"`
+	Size int    `xml:"Size"`
+}
+
+// list implements ListObjectsV2, the continuation token is the last key returned
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("list-type") != "2" {
+		writeError(w, http.StatusNotImplemented, "NotImplemented", "only ListObjectsV2 is supported")
+		return
+	}
+	prefix := query.Get("prefix")
+	token := query.Get("continuation-token")
+	maxKeys := s.MaxKeys
+	if maxKeys == 0 {
+		maxKeys = 1000
+	}
+
+	s.mu.Lock()
+	var keys []string
+	for key := range s.objects {
+		if strings.HasPrefix(key, prefix) && key > token {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	result := listBucketResult{
+		Name:              s.bucket,
+		Prefix:            prefix,
+		MaxKeys:           maxKeys,
+		ContinuationToken: token,
+	}
+	for _, key := range keys {
+		if len(result.Contents) == maxKeys {
+			result.IsTruncated = true
+			result.NextContinuationToken = result.Contents[len(result.Contents)-1].Key
+			break
+		}
+		result.Contents = append(result.Contents, listEntry{Key: key, Size: len(s.objects[key].data)})
+	}
+	s.mu.Unlock()
+	result.KeyCount = len(result.Contents)
+
+	writeXML(w, http.StatusOK, result)
+}
+
+type errorResponse struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string   `xml:"Code"`
+	Message string   `xml:"Message"`
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeXML(w, status, errorResponse{Code: code, Message: message})
+}
+
+func writeXML(w http.ResponseWriter, status int, v any) {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(xml.Header))
+	_, _ = w.Write(data)
+}
@@ -45,6 +45,12 @@ type Builder struct {
 // |  +-----------------------------------------+  |
 // |  |  Key                                    |  |
 // |  +-----------------------------------------+  |
+// |  |  Sequence Number (8 bytes)              |  |
+// |  +-----------------------------------------+  |
+// |  |  Flags (1 byte)                         |  |
+// |  +-----------------------------------------+  |
+// |  |  Expire At (8 bytes, if FlagExpires)    |  |
+// |  +-----------------------------------------+  |
 // |  |  Value Length (4 bytes)                 |  |
 // |  +-----------------------------------------+  |
 // |  |  Value                                  |  |
@@ -61,6 +67,10 @@ type Builder struct {
 // |  +-----------------------------------------+  |
 // |  |  Key                                    |  |
 // |  +-----------------------------------------+  |
+// |  |  Sequence Number (8 bytes)              |  |
+// |  +-----------------------------------------+  |
+// |  |  Flags (1 byte)                         |  |
+// |  +-----------------------------------------+  |
 // |  |  Tombstone (4 bytes)                    |  |
 // |  +-----------------------------------------+  |
 // +-----------------------------------------------+
@@ -82,14 +92,35 @@ func (b *Builder) estimatedSize() int {
 		len(b.data) // key-value pairs
 }
 
+// Add adds the key and value to the block, returning false if the block is full. An empty
+// value is encoded as a tombstone, use AddEntry to add an empty value which is not a tombstone.
 func (b *Builder) Add(key []byte, value []byte) bool {
-	assert.True(len(key) > 0, "key must not be empty")
+	return b.AddEntry(types.KeyValue{
+		Key:   key,
+		Value: types.Value{Value: value, IsTombstone: len(value) == 0},
+	})
+}
 
+// AddEntry adds the key value pair to the block, returning false if the block is full.
+// If entry.Value.IsTombstone is true, the entry is encoded as a tombstone.
+// If entry.Value.IsMerge is true, the value is encoded as a merge operand.
+func (b *Builder) AddEntry(entry types.KeyValue) bool {
+	assert.True(len(entry.Key) > 0, "key must not be empty")
+
+	var flags byte
 	valueLen := 0
-	if len(value) > 0 {
-		valueLen = len(value)
+	if !entry.Value.IsTombstone {
+		valueLen = len(entry.Value.Value)
+		if entry.Value.IsMerge {
+			flags |= types.FlagMerge
+		}
+		if entry.Value.ExpireAt != 0 {
+			flags |= types.FlagExpires
+			valueLen += types.SizeOfUint64
+		}
 	}
-	newSize := b.estimatedSize() + len(key) + valueLen + (types.SizeOfUint16 * 2) + types.SizeOfUint32
+	newSize := b.estimatedSize() + len(entry.Key) + valueLen +
+		(types.SizeOfUint16 * 2) + types.SizeOfUint64 + 1 + types.SizeOfUint32
 
 	// If adding the key-value pair would exceed the block size limit, don't add it.
 	// (Unless the block is empty, in which case, allow the block to exceed the limit.)
@@ -99,13 +130,19 @@ func (b *Builder) Add(key []byte, value []byte) bool {
 
 	b.offsets = append(b.offsets, uint16(len(b.data)))
 
-	// If value is present then append KeyLength(uint16), Key, ValueLength(uint32), value.
-	// if value is absent then append KeyLength(uint16), Key, Tombstone(uint32)
-	b.data = binary.BigEndian.AppendUint16(b.data, uint16(len(key)))
-	b.data = append(b.data, key...)
-	if valueLen > 0 {
-		b.data = binary.BigEndian.AppendUint32(b.data, uint32(valueLen))
-		b.data = append(b.data, value...)
+	// If not a tombstone then append KeyLength(uint16), Key, Seq(uint64), Flags(uint8),
+	// ExpireAt(uint64) if FlagExpires, ValueLength(uint32), value. If it is a tombstone
+	// then append KeyLength(uint16), Key, Seq(uint64), Flags(uint8), Tombstone(uint32)
+	b.data = binary.BigEndian.AppendUint16(b.data, uint16(len(entry.Key)))
+	b.data = append(b.data, entry.Key...)
+	b.data = binary.BigEndian.AppendUint64(b.data, entry.Seq)
+	b.data = append(b.data, flags)
+	if flags&types.FlagExpires != 0 {
+		b.data = binary.BigEndian.AppendUint64(b.data, uint64(entry.Value.ExpireAt))
+	}
+	if !entry.Value.IsTombstone {
+		b.data = binary.BigEndian.AppendUint32(b.data, uint32(len(entry.Value.Value)))
+		b.data = append(b.data, entry.Value.Value...)
 	} else {
 		b.data = binary.BigEndian.AppendUint32(b.data, types.Tombstone)
 	}
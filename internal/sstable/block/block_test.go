@@ -6,7 +6,9 @@ import (
 	"github.com/thrawn01/lsm-go/internal/compress"
 	"github.com/thrawn01/lsm-go/internal/sstable/block"
 	"github.com/thrawn01/lsm-go/internal/sstable/types"
+	"math"
 	"testing"
+	"time"
 )
 
 func TestNewBuilder(t *testing.T) {
@@ -72,6 +74,43 @@ func TestBlockWithTombstone(t *testing.T) {
 	assert.Equal(t, b.Offsets, decoded.Offsets)
 }
 
+func TestBuilderAddEntry(t *testing.T) {
+	entries := []types.KeyValue{
+		{Key: []byte("key1"), Value: types.Value{Value: []byte("value1")}, Seq: 1},
+		{Key: []byte("key2"), Value: types.Value{IsTombstone: true}, Seq: 2},
+		{Key: []byte("key3"), Value: types.Value{Value: []byte("")}, Seq: math.MaxUint64},
+		{Key: []byte("key4"), Value: types.Value{Value: []byte("operand"), IsMerge: true}, Seq: 4},
+		{Key: []byte("key5"), Value: types.Value{Value: []byte("operand"), IsMerge: true, ExpireAt: 1000}, Seq: 5},
+	}
+
+	bb := block.NewBuilder(4096)
+	for _, e := range entries {
+		assert.True(t, bb.AddEntry(e))
+	}
+
+	b, err := bb.Build()
+	assert.NoError(t, err)
+
+	encoded, err := block.Encode(b, compress.CodecNone)
+	assert.NoError(t, err)
+	var decoded block.Block
+	assert.NoError(t, block.Decode(&decoded, encoded, compress.CodecNone))
+
+	iter := block.NewIterator(&decoded)
+	for _, e := range entries {
+		kv, ok := iter.NextEntry()
+		assert.True(t, ok)
+		assert.Equal(t, e.Key, kv.Key)
+		assert.Equal(t, e.Seq, kv.Seq)
+		assert.Equal(t, e.Value.IsTombstone, kv.Value.IsTombstone)
+		assert.Equal(t, e.Value.IsMerge, kv.Value.IsMerge)
+		assert.Equal(t, e.Value.ExpireAt, kv.Value.ExpireAt)
+		assert.True(t, bytes.Equal(e.Value.Value, kv.Value.Value))
+	}
+	_, ok := iter.NextEntry()
+	assert.False(t, ok)
+}
+
 func TestBlockIterator(t *testing.T) {
 	kvPairs := []types.KV{
 		{Key: []byte("donkey"), Value: []byte("kong")},
@@ -151,6 +190,129 @@ func TestNewIteratorAtKey(t *testing.T) {
 	})
 }
 
+func TestBlockIteratorExpired(t *testing.T) {
+	past := time.Now().Add(-time.Minute).UnixMilli()
+	future := time.Now().Add(time.Hour).UnixMilli()
+	entries := []types.KeyValue{
+		{Key: []byte("key1"), Value: types.Value{Value: []byte("value1"), ExpireAt: past}},
+		{Key: []byte("key2"), Value: types.Value{Value: []byte("value2"), ExpireAt: future}},
+		{Key: []byte("key3"), Value: types.Value{Value: []byte("value3")}},
+	}
+
+	bb := block.NewBuilder(4096)
+	for _, e := range entries {
+		assert.True(t, bb.AddEntry(e))
+	}
+	b, err := bb.Build()
+	assert.NoError(t, err)
+
+	// NextEntry returns the expiration time of every entry
+	iter := block.NewIterator(b)
+	for _, e := range entries {
+		kv, ok := iter.NextEntry()
+		assert.True(t, ok)
+		assert.Equal(t, e.Key, kv.Key)
+		assert.Equal(t, e.Value.ExpireAt, kv.Value.ExpireAt)
+		assert.Equal(t, e.Value.Value, kv.Value.Value)
+	}
+
+	// Next skips the expired entry
+	iter = block.NewIterator(b)
+	for _, e := range entries[1:] {
+		kv, ok := iter.Next()
+		assert.True(t, ok)
+		assert.Equal(t, e.Key, kv.Key)
+	}
+	_, ok := iter.Next()
+	assert.False(t, ok)
+}
+
+func TestBlockIteratorPrev(t *testing.T) {
+	past := time.Now().Add(-time.Minute).UnixMilli()
+	entries := []types.KeyValue{
+		{Key: []byte("key1"), Value: types.Value{Value: []byte("value1")}},
+		{Key: []byte("key2"), Value: types.Value{IsTombstone: true}},
+		{Key: []byte("key3"), Value: types.Value{Value: []byte("value3"), ExpireAt: past}},
+		{Key: []byte("key4"), Value: types.Value{Value: []byte("value4")}},
+	}
+
+	bb := block.NewBuilder(4096)
+	for _, e := range entries {
+		assert.True(t, bb.AddEntry(e))
+	}
+	b, err := bb.Build()
+	assert.NoError(t, err)
+
+	// PrevEntry returns every entry in reverse order
+	iter := block.NewIterator(b)
+	iter.SeekToLast()
+	for i := len(entries) - 1; i >= 0; i-- {
+		kv, ok := iter.PrevEntry()
+		assert.True(t, ok)
+		assert.Equal(t, entries[i].Key, kv.Key)
+		assert.Equal(t, entries[i].Value.IsTombstone, kv.Value.IsTombstone)
+	}
+	_, ok := iter.PrevEntry()
+	assert.False(t, ok)
+
+	// Prev skips tombstones and expired values
+	iter.SeekToLast()
+	var keys []string
+	for {
+		kv, ok := iter.Prev()
+		if !ok {
+			break
+		}
+		keys = append(keys, string(kv.Key))
+	}
+	assert.Equal(t, []string{"key4", "key1"}, keys)
+
+	// Prev after Next returns the same entry
+	iter = block.NewIterator(b)
+	kv, ok := iter.NextEntry()
+	assert.True(t, ok)
+	assert.Equal(t, []byte("key1"), kv.Key)
+	kv, ok = iter.PrevEntry()
+	assert.True(t, ok)
+	assert.Equal(t, []byte("key1"), kv.Key)
+	_, ok = iter.PrevEntry()
+	assert.False(t, ok)
+}
+
+func TestBlockIteratorSeekForPrev(t *testing.T) {
+	builder := block.NewBuilder(1024)
+	for _, key := range []string{"donkey", "kratos", "super"} {
+		assert.True(t, builder.Add([]byte(key), []byte("value")))
+	}
+	b, err := builder.Build()
+	assert.NoError(t, err)
+
+	for _, tt := range []struct {
+		name     string
+		key      string
+		expected []string
+	}{
+		{name: "KeyFound", key: "kratos", expected: []string{"kratos", "donkey"}},
+		{name: "KeyNotFound", key: "ka", expected: []string{"donkey"}},
+		{name: "KeyAtEnd", key: "zzz", expected: []string{"super", "kratos", "donkey"}},
+		{name: "KeyAtStart", key: "a", expected: nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			iter := block.NewIterator(b)
+			iter.SeekForPrev([]byte(tt.key))
+			var keys []string
+			for {
+				kv, ok := iter.Prev()
+				if !ok {
+					break
+				}
+				keys = append(keys, string(kv.Key))
+			}
+			assert.Equal(t, tt.expected, keys)
+		})
+	}
+}
+
 func testCompression(t *testing.T, codec compress.Codec) {
 	t.Helper()
 	bb := block.NewBuilder(4096)
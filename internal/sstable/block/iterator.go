@@ -5,11 +5,15 @@ import (
 	"encoding/binary"
 	"github.com/thrawn01/lsm-go/internal/sstable/types"
 	"sort"
+	"time"
 )
 
-// Iterator iterates through KeyValue pairs present in the Block.
+// Iterator iterates through KeyValue pairs present in the Block. The iterator is positioned
+// between two entries, Next returns the entry after the position and Prev returns the entry
+// before the position, such that calling Prev after Next returns the same entry.
 type Iterator struct {
-	block       *Block
+	block *Block
+	// offsetIndex is the index of the entry returned by the next call to NextEntry
 	offsetIndex uint64
 }
 
@@ -23,27 +27,37 @@ func NewIterator(block *Block) *Iterator {
 // NewIteratorAtKey Construct an Iterator that starts at the given key, or at the first
 // key greater than the given key if the exact key given is not in the block.
 func NewIteratorAtKey(block *Block, key []byte) *Iterator {
-	index := sort.Search(len(block.Offsets), func(i int) bool {
-		off := block.Offsets[i]
-		keyLen := binary.BigEndian.Uint16(block.Data[off:])
-		off += types.SizeOfUint16
-		curKey := block.Data[off : off+keyLen]
-		return bytes.Compare(curKey, key) >= 0
-	})
+	iter := &Iterator{block: block}
+	iter.offsetIndex = uint64(sort.Search(len(block.Offsets), func(i int) bool {
+		return bytes.Compare(iter.keyAt(i), key) >= 0
+	}))
+	return iter
+}
 
-	return &Iterator{
-		block:       block,
-		offsetIndex: uint64(index),
-	}
+// SeekForPrev positions the iterator after the given key, or after the last key less than
+// the given key if the exact key is not in the block, such that Prev returns the key.
+func (iter *Iterator) SeekForPrev(key []byte) {
+	iter.offsetIndex = uint64(sort.Search(len(iter.block.Offsets), func(i int) bool {
+		return bytes.Compare(iter.keyAt(i), key) > 0
+	}))
+}
+
+// SeekToLast positions the iterator after the last key in the block, such that Prev
+// returns the last key.
+func (iter *Iterator) SeekToLast() {
+	iter.offsetIndex = uint64(len(iter.block.Offsets))
 }
 
+// Next returns the next key value pair in the block, skipping tombstones and expired values.
+// Merge operands are returned as is, use NextEntry to distinguish merge operands from values.
 func (iter *Iterator) Next() (types.KV, bool) {
+	now := time.Now()
 	for {
 		entry, ok := iter.NextEntry()
 		if !ok {
 			return types.KV{}, false
 		}
-		if entry.Value.IsTombstone {
+		if entry.Value.IsTombstone || entry.Value.IsExpired(now) {
 			continue
 		}
 		return types.KV{
@@ -53,23 +67,80 @@ func (iter *Iterator) Next() (types.KV, bool) {
 	}
 }
 
+// NextEntry returns the next entry in the block, including tombstones and expired values
 func (iter *Iterator) NextEntry() (types.KeyValue, bool) {
-
 	if iter.offsetIndex >= uint64(len(iter.block.Offsets)) {
 		return types.KeyValue{}, false
 	}
+	result := iter.entryAt(iter.offsetIndex)
+	iter.offsetIndex += 1
+	return result, true
+}
+
+// Prev returns the previous key value pair in the block, skipping tombstones and expired
+// values. Merge operands are returned as is, use PrevEntry to distinguish merge operands
+// from values.
+func (iter *Iterator) Prev() (types.KV, bool) {
+	now := time.Now()
+	for {
+		entry, ok := iter.PrevEntry()
+		if !ok {
+			return types.KV{}, false
+		}
+		if entry.Value.IsTombstone || entry.Value.IsExpired(now) {
+			continue
+		}
+		return types.KV{
+			Key:   entry.Key,
+			Value: entry.Value.Value,
+		}, true
+	}
+}
+
+// PrevEntry returns the previous entry in the block, including tombstones and expired values
+func (iter *Iterator) PrevEntry() (types.KeyValue, bool) {
+	if iter.offsetIndex == 0 {
+		return types.KeyValue{}, false
+	}
+	iter.offsetIndex -= 1
+	return iter.entryAt(iter.offsetIndex), true
+}
+
+// keyAt returns the key of the entry at the provided index of Block.Offsets
+func (iter *Iterator) keyAt(i int) []byte {
+	off := iter.block.Offsets[i]
+	keyLen := binary.BigEndian.Uint16(iter.block.Data[off:])
+	off += types.SizeOfUint16
+	return iter.block.Data[off : off+keyLen]
+}
+
+// entryAt decodes the entry at the provided index of Block.Offsets
+func (iter *Iterator) entryAt(index uint64) types.KeyValue {
 	var result types.KeyValue
 
 	data := iter.block.Data
-	offset := iter.block.Offsets[iter.offsetIndex]
+	offset := iter.block.Offsets[index]
 
-	// Read KeyLength(uint16), Key, (ValueLength(uint32), value)/Tombstone(uint32) from data
+	// Read KeyLength(uint16), Key, Seq(uint64), Flags(uint8), ExpireAt(uint64) if FlagExpires,
+	// (ValueLength(uint32), value)/Tombstone(uint32) from data
 	keyLen := binary.BigEndian.Uint16(data[offset:])
 	offset += types.SizeOfUint16
 
 	result.Key = data[offset : offset+keyLen]
 	offset += keyLen
 
+	result.Seq = binary.BigEndian.Uint64(data[offset:])
+	offset += types.SizeOfUint64
+
+	flags := data[offset]
+	offset += 1
+
+	var expireAt int64
+	if flags&types.FlagExpires != 0 {
+		expireAt = int64(binary.BigEndian.Uint64(data[offset:]))
+		offset += types.SizeOfUint64
+	}
+
 	valueLen := binary.BigEndian.Uint32(data[offset:])
 	offset += types.SizeOfUint32
 
@@ -77,13 +148,13 @@ func (iter *Iterator) NextEntry() (types.KeyValue, bool) {
 		result.Value = types.Value{
 			Value:       data[offset : uint32(offset)+valueLen],
 			IsTombstone: false,
+			IsMerge:     flags&types.FlagMerge != 0,
+			ExpireAt:    expireAt,
 		}
 	} else {
 		result.Value = types.Value{
 			IsTombstone: true,
 		}
 	}
-
-	iter.offsetIndex += 1
-	return result, true
+	return result
 }
@@ -5,6 +5,7 @@ import (
 	"github.com/thrawn01/lsm-go/internal/flatbuf"
 	"github.com/thrawn01/lsm-go/internal/sstable/block"
 	"github.com/thrawn01/lsm-go/internal/sstable/bloom"
+	"github.com/thrawn01/lsm-go/internal/sstable/types"
 )
 
 // Builder builds the SSTable in the format outlined
@@ -27,6 +28,9 @@ import (
 // |  |  |  |  +---------------------------+ |  |  |
 // |  |  |  |  |  Key Length (2 bytes)     | |  |  |
 // |  |  |  |  |  Key                      | |  |  |
+// |  |  |  |  |  Sequence (8 bytes)       | |  |  |
+// |  |  |  |  |  Flags (1 byte)           | |  |  |
+// |  |  |  |  |  Expire At (8 bytes)      | |  |  |
 // |  |  |  |  |  Value Length (4 bytes)   | |  |  |
 // |  |  |  |  |  Value                    | |  |  |
 // |  |  |  |  +---------------------------+ |  |  |
@@ -60,6 +64,8 @@ import (
 // |  |  - Length of BloomFilter                |  |
 // |  |  - Offset of sstable.Index              |  |
 // |  |  - Length of sstable.Index              |  |
+// |  |  - Min and Max Sequence Numbers         |  |
+// |  |  - Writer Epoch                         |  |
 // |  +-----------------------------------------+  |
 // |                                               |
 // |  +-----------------------------------------+  |
@@ -73,6 +79,9 @@ type Builder struct {
 	bloomBuilder *bloom.Builder
 	keyCount     int
 	firstKey     []byte
+	minSeq       uint64
+	maxSeq       uint64
+	writerEpoch  uint64
 }
 
 // NewBuilder creates a new builder used to encode an SSTable
@@ -84,15 +93,30 @@ func NewBuilder(conf Config) *Builder {
 	}
 }
 
-// Add a key and value to the SSTable.
+// SetWriterEpoch sets the epoch of the writer which is recorded in the sstable.Info
+func (bu *Builder) SetWriterEpoch(epoch uint64) {
+	bu.writerEpoch = epoch
+}
+
+// Add a key and value to the SSTable. An empty value is encoded as a tombstone,
+// use AddEntry to add an empty value which is not a tombstone.
 func (bu *Builder) Add(key, value []byte) error {
+	return bu.AddEntry(types.KeyValue{
+		Key:   key,
+		Value: types.Value{Value: value, IsTombstone: len(value) == 0},
+	})
+}
+
+// AddEntry adds a key value pair which may be a tombstone to the SSTable.
+// Entries must be added in ascending key order.
+func (bu *Builder) AddEntry(entry types.KeyValue) error {
 	if len(bu.blocks) == 0 && bu.blockBuilder.IsEmpty() {
-		bu.firstKey = make([]byte, len(key))
-		copy(bu.firstKey, key)
+		bu.firstKey = make([]byte, len(entry.Key))
+		copy(bu.firstKey, entry.Key)
 	}
 
-	if !bu.blockBuilder.Add(key, value) {
-		// Add returns false if current block is full.
+	if !bu.blockBuilder.AddEntry(entry) {
+		// AddEntry returns false if current block is full.
 		// Build the current block and start a new one
 		blk, err := bu.blockBuilder.Build()
 		if err != nil {
@@ -100,10 +124,16 @@ func (bu *Builder) Add(key, value []byte) error {
 		}
 		bu.blocks = append(bu.blocks, blk)
 		bu.blockBuilder = block.NewBuilder(uint64(bu.conf.BlockSize))
-		bu.blockBuilder.Add(key, value)
+		bu.blockBuilder.AddEntry(entry)
 	}
 
-	bu.bloomBuilder.Add(key)
+	if bu.keyCount == 0 || entry.Seq < bu.minSeq {
+		bu.minSeq = entry.Seq
+	}
+	if entry.Seq > bu.maxSeq {
+		bu.maxSeq = entry.Seq
+	}
+	bu.bloomBuilder.Add(entry.Key)
 	bu.keyCount++
 
 	return nil
@@ -132,6 +162,9 @@ func (bu *Builder) Build() *Table {
 	info := &Info{
 		FirstKey:         bu.firstKey,
 		CompressionCodec: bu.conf.Compression,
+		MinSeq:           bu.minSeq,
+		MaxSeq:           bu.maxSeq,
+		WriterEpoch:      bu.writerEpoch,
 	}
 
 	var bloomFilter *bloom.Filter
@@ -22,6 +22,10 @@ func (d *Decoder) ReadInfo(b ReadOnlyBlob) (*Info, error) {
 	if err != nil {
 		return nil, err
 	}
+	if size < types.SizeOfUint32 {
+		return nil, fmt.Errorf("SSTable '%s' Corrupted: blob size is too small; expected atleast"+
+			" 4 byte length, got %d", b.Id(), size)
+	}
 
 	// Read the last 8 bytes to get the offset of the Info
 	offsetBytes, err := b.ReadRange(Range{Start: size - types.SizeOfUint32, End: size})
@@ -46,7 +50,10 @@ func (d *Decoder) ReadInfo(b ReadOnlyBlob) (*Info, error) {
 	}
 
 	// Decode the Info
-	info := decodeInfo(infoBytes)
+	info, err := decodeInfo(infoBytes)
+	if err != nil {
+		return nil, fmt.Errorf("SSTable '%s' Corrupted: %w", b.Id(), err)
+	}
 
 	if err := validInfo(info, size, b.Id()); err != nil {
 		return nil, err
@@ -179,6 +186,10 @@ func (d *Decoder) ReadBlocks(info *Info, idx *Index, r Range, b ReadOnlyBlob) ([
 // size of the SSTable. If not, returns an error in the form
 // "SSTable '<id>' Corrupted: <why>"
 func validInfo(info *Info, size uint64, id string) error {
+	// Every SSTable has an index, even if the SSTable has no blocks
+	if info.IndexLen == 0 {
+		return fmt.Errorf("SSTable '%s' Corrupted: index length is zero", id)
+	}
 	if info.IndexOffset >= size {
 		return fmt.Errorf("SSTable '%s' Corrupted: index offset %d is greater than or equal to SSTable size %d",
 			id, info.IndexOffset, size)
@@ -5,6 +5,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/thrawn01/lsm-go/internal/compress"
 	"github.com/thrawn01/lsm-go/internal/sstable/block"
+	"github.com/thrawn01/lsm-go/internal/sstable/types"
 	"testing"
 )
 
@@ -72,6 +73,28 @@ func TestDecoder_ReadInfo(t *testing.T) {
 	assert.Equal(t, table.Info.CompressionCodec, info.CompressionCodec)
 }
 
+func TestDecoder_ReadInfoSeq(t *testing.T) {
+	conf := Config{
+		BlockSize:        1024,
+		MinFilterKeys:    10,
+		FilterBitsPerKey: 10,
+		Compression:      compress.CodecNone,
+	}
+	builder := NewBuilder(conf)
+	assert.NoError(t, builder.AddEntry(types.KeyValue{Key: []byte("key1"), Value: types.Value{Value: []byte("value1")}, Seq: 7}))
+	assert.NoError(t, builder.AddEntry(types.KeyValue{Key: []byte("key2"), Value: types.Value{IsTombstone: true}, Seq: 3}))
+	assert.NoError(t, builder.AddEntry(types.KeyValue{Key: []byte("key3"), Value: types.Value{Value: []byte("value3")}, Seq: 12}))
+	table := builder.Build()
+	assert.Equal(t, uint64(3), table.Info.MinSeq)
+	assert.Equal(t, uint64(12), table.Info.MaxSeq)
+
+	decoder := &Decoder{Config: conf}
+	info, err := decoder.ReadInfo(&mockBlob{data: table.Data})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), info.MinSeq)
+	assert.Equal(t, uint64(12), info.MaxSeq)
+}
+
 func TestDecoder_ReadBloom(t *testing.T) {
 	// Create a sample SSTable using the Builder
 	builder := NewBuilder(Config{
@@ -0,0 +1,95 @@
+package sstable_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/lsm-go/internal/compress"
+	"github.com/thrawn01/lsm-go/internal/sstable"
+	"github.com/thrawn01/lsm-go/internal/sstable/types"
+	"github.com/thrawn01/lsm-go/objstore"
+)
+
+func TestDecoderReadInfoFaults(t *testing.T) {
+	conf := sstable.Config{
+		BlockSize:        1024,
+		MinFilterKeys:    10,
+		FilterBitsPerKey: 10,
+		Compression:      compress.CodecNone,
+	}
+	builder := sstable.NewBuilder(conf)
+	require.NoError(t, builder.AddEntry(types.KeyValue{Key: []byte("key1"), Value: types.Value{Value: []byte("value1")}, Seq: 1}))
+	require.NoError(t, builder.AddEntry(types.KeyValue{Key: []byte("key2"), Value: types.Value{Value: []byte("value2")}, Seq: 2}))
+	table := builder.Build()
+	size := uint64(len(table.Data))
+	errNetwork := errors.New("network error")
+
+	for _, tt := range []struct {
+		name  string
+		fault objstore.Fault
+		// err is the expected error, nil if only an error is expected
+		err error
+		// contains is a substring of the expected error
+		contains string
+		ok       bool
+	}{
+		{
+			name:  "Latency",
+			fault: objstore.Fault{Op: objstore.OpRead, Latency: 10 * time.Millisecond},
+			ok:    true,
+		},
+		{
+			name:  "LenError",
+			fault: objstore.Fault{Op: objstore.OpRead, Count: 1, Err: errNetwork},
+			err:   errNetwork,
+		},
+		{
+			// Skip the Len() and the read of the Info offset
+			name:  "ReadInfoError",
+			fault: objstore.Fault{Op: objstore.OpRead, Skip: 2, Count: 1, Err: errNetwork},
+			err:   errNetwork,
+		},
+		{
+			name:     "TornTailSmallerThanOffset",
+			fault:    objstore.Fault{Op: objstore.OpWrite, TornTail: int(size) - 2},
+			contains: "blob size is too small",
+		},
+		{
+			name:     "TornTail",
+			fault:    objstore.Fault{Op: objstore.OpWrite, TornTail: 7},
+			contains: "Corrupted",
+		},
+		{
+			// Flip the most significant bit of the Info offset
+			name:     "BitFlipInfoOffset",
+			fault:    objstore.Fault{Op: objstore.OpWrite, BitFlips: []uint64{(size-4)*8 + 7}},
+			contains: "invalid Info offset",
+		},
+		{
+			name:     "BitFlipInfo",
+			fault:    objstore.Fault{Op: objstore.OpRead, BitFlips: []uint64{(size - 16) * 8}},
+			contains: "Corrupted",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			store := objstore.NewMemoryStore()
+			store.Inject(tt.fault)
+			_ = store.Put("sst/1.sst", table.Data)
+
+			info, err := (&sstable.Decoder{Config: conf}).ReadInfo(objstore.NewBlob(store, "sst/1.sst"))
+			if tt.ok {
+				require.NoError(t, err)
+				assert.Equal(t, table.Info.IndexOffset, info.IndexOffset)
+				return
+			}
+			require.Error(t, err)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+			}
+			assert.Contains(t, err.Error(), tt.contains)
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package sstable
 
 import (
+	"fmt"
+
 	"github.com/google/flatbuffers/go"
 	"github.com/thrawn01/lsm-go/internal/compress"
 	"github.com/thrawn01/lsm-go/internal/flatbuf"
@@ -58,21 +60,35 @@ func encodeInfo(info *Info) []byte {
 	flatbuf.SsTableInfoAddFilterOffset(builder, info.FilterOffset)
 	flatbuf.SsTableInfoAddFilterLen(builder, info.FilterLen)
 	flatbuf.SsTableInfoAddCompressionFormat(builder, flatbuf.CompressionFormat(info.CompressionCodec))
+	flatbuf.SsTableInfoAddMinSeq(builder, info.MinSeq)
+	flatbuf.SsTableInfoAddMaxSeq(builder, info.MaxSeq)
+	flatbuf.SsTableInfoAddWriterEpoch(builder, info.WriterEpoch)
 	infoOffset := flatbuf.SsTableInfoEnd(builder)
 
 	builder.Finish(infoOffset)
 	return builder.FinishedBytes()
 }
 
-func decodeInfo(b []byte) *Info {
+// decodeInfo decodes the Info from the flat buffer. The flatbuffers package panics when
+// accessing a field beyond the end of a corrupted buffer, which is returned as an error.
+func decodeInfo(b []byte) (info *Info, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("while decoding flat buffer: %v", r)
+		}
+	}()
+
 	fbInfo := flatbuf.GetRootAsSsTableInfo(b, 0)
-	info := &Info{
+	info = &Info{
 		FirstKey:         fbInfo.FirstKeyBytes(),
 		IndexOffset:      fbInfo.IndexOffset(),
 		IndexLen:         fbInfo.IndexLen(),
 		FilterOffset:     fbInfo.FilterOffset(),
 		FilterLen:        fbInfo.FilterLen(),
 		CompressionCodec: compress.Codec(fbInfo.CompressionFormat()),
+		MinSeq:           fbInfo.MinSeq(),
+		MaxSeq:           fbInfo.MaxSeq(),
+		WriterEpoch:      fbInfo.WriterEpoch(),
 	}
-	return info
+	return info, nil
 }
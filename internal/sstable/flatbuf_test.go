@@ -43,13 +43,17 @@ func TestEncodeDecodeInfo(t *testing.T) {
 		FilterOffset:     1500,
 		FilterLen:        200,
 		CompressionCodec: compress.CodecSnappy,
+		MinSeq:           10,
+		MaxSeq:           20,
+		WriterEpoch:      3,
 	}
 
 	// Encode the Info
 	encoded := encodeInfo(info)
 
 	// Decode the Info
-	decoded := decodeInfo(encoded)
+	decoded, err := decodeInfo(encoded)
+	assert.NoError(t, err)
 
 	// Check if the decoded Info matches the original
 	assert.Equal(t, info.FirstKey, decoded.FirstKey)
@@ -58,6 +62,9 @@ func TestEncodeDecodeInfo(t *testing.T) {
 	assert.Equal(t, info.FilterOffset, decoded.FilterOffset)
 	assert.Equal(t, info.FilterLen, decoded.FilterLen)
 	assert.Equal(t, info.CompressionCodec, decoded.CompressionCodec)
+	assert.Equal(t, info.MinSeq, decoded.MinSeq)
+	assert.Equal(t, info.MaxSeq, decoded.MaxSeq)
+	assert.Equal(t, info.WriterEpoch, decoded.WriterEpoch)
 }
 
 func TestIndexAsFlatBuf(t *testing.T) {
@@ -88,6 +95,9 @@ func TestInfoClone(t *testing.T) {
 		FilterOffset:     1500,
 		FilterLen:        200,
 		CompressionCodec: compress.CodecSnappy,
+		MinSeq:           10,
+		MaxSeq:           20,
+		WriterEpoch:      3,
 	}
 
 	// Clone the Info
@@ -100,6 +110,9 @@ func TestInfoClone(t *testing.T) {
 	assert.Equal(t, original.FilterOffset, cloned.FilterOffset)
 	assert.Equal(t, original.FilterLen, cloned.FilterLen)
 	assert.Equal(t, original.CompressionCodec, cloned.CompressionCodec)
+	assert.Equal(t, original.MinSeq, cloned.MinSeq)
+	assert.Equal(t, original.MaxSeq, cloned.MaxSeq)
+	assert.Equal(t, original.WriterEpoch, cloned.WriterEpoch)
 
 	// Modify the original to ensure deep copy
 	original.FirstKey[0] = 'x'
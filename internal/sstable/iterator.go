@@ -0,0 +1,263 @@
+package sstable
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/thrawn01/lsm-go/internal/flatbuf"
+	"github.com/thrawn01/lsm-go/internal/sstable/block"
+	"github.com/thrawn01/lsm-go/internal/sstable/types"
+)
+
+// Iterator iterates through the KeyValue pairs of every block in the SSTable in key order.
+// Blocks are read from the ReadOnlyBlob one at a time as the iterator advances, such that
+// only the blocks visited are read.
+//
+// The iterator is positioned between two entries, Next returns the entry after the position
+// and Prev returns the entry before the position, such that calling Prev after Next returns
+// the same entry.
+type Iterator struct {
+	decoder *Decoder
+	info    *Info
+	index   *Index
+	meta    []*flatbuf.BlockMetaT
+	blob    ReadOnlyBlob
+
+	// keyRange is the range of keys returned by the iterator
+	keyRange KeyRange
+	// startBlock and endBlock are the range [startBlock, endBlock) of blocks which may
+	// contain keys in the range, blocks outside the range are never read.
+	startBlock int
+	endBlock   int
+
+	// blockIter iterates through the block at blockIdx, nil if the block has not been read
+	blockIter *block.Iterator
+	blockIdx  int
+	// open returns the block iterator positioned within the block at blockIdx once read
+	open func(b *block.Block) *block.Iterator
+	err  error
+}
+
+// NewIterator returns an Iterator positioned at the first key of the SSTable. The Info and
+// Index are those read from the blob using Decoder.ReadInfo() and Decoder.ReadIndex().
+func NewIterator(d *Decoder, info *Info, index *Index, b ReadOnlyBlob) *Iterator {
+	return NewRangeIterator(d, info, index, b, KeyRange{})
+}
+
+// NewRangeIterator returns an Iterator over the keys of the SSTable within the range,
+// positioned at the first key in the range. Only the blocks which may contain keys in the
+// range are read, which are found using the first key of each block in the Index.
+func NewRangeIterator(d *Decoder, info *Info, index *Index, b ReadOnlyBlob, r KeyRange) *Iterator {
+	iter := &Iterator{
+		decoder:  d,
+		info:     info,
+		index:    index,
+		blob:     b,
+		keyRange: r,
+	}
+	// A table without an index has no blocks
+	if index != nil {
+		iter.meta = index.AsFlatBuf().BlockMeta
+	}
+
+	// The last block which may contain keys in the range is the last block with a first
+	// key in the range
+	iter.endBlock = sort.Search(len(iter.meta), func(i int) bool {
+		return !r.beforeEnd(iter.meta[i].FirstKey)
+	})
+	if r.Start != nil {
+		iter.startBlock = max(findBlock(iter.meta, r.Start), 0)
+		iter.Seek(r.Start)
+		return iter
+	}
+	iter.setBlock(0, block.NewIterator)
+	return iter
+}
+
+// Seek positions the iterator before the given key, or before the first key greater than
+// the given key if the exact key is not in the SSTable, such that Next returns the key. The
+// block which may contain the key is found using the first key of each block in the Index,
+// and is read by the next call to Next() or Prev(). Seeking to a key outside the range of
+// the iterator positions the iterator at the start or the end of the range.
+func (iter *Iterator) Seek(key []byte) {
+	if !iter.keyRange.afterStart(key) {
+		key = iter.keyRange.Start
+	}
+	if !iter.keyRange.beforeEnd(key) {
+		iter.SeekToLast()
+		return
+	}
+	iter.setBlock(findBlock(iter.meta, key), func(b *block.Block) *block.Iterator {
+		return block.NewIteratorAtKey(b, key)
+	})
+}
+
+// SeekForPrev positions the iterator after the given key, or after the last key less than
+// the given key if the exact key is not in the SSTable, such that Prev returns the key.
+// Seeking to a key beyond the end of the range of the iterator positions the iterator at
+// the end of the range.
+func (iter *Iterator) SeekForPrev(key []byte) {
+	if !iter.keyRange.beforeEnd(key) {
+		iter.SeekToLast()
+		return
+	}
+	i := findBlock(iter.meta, key)
+	if i < iter.startBlock {
+		// Every key is after the given key
+		iter.setBlock(iter.startBlock, block.NewIterator)
+		return
+	}
+	iter.setBlock(i, func(b *block.Block) *block.Iterator {
+		blockIter := block.NewIterator(b)
+		blockIter.SeekForPrev(key)
+		return blockIter
+	})
+}
+
+// SeekToLast positions the iterator after the last key in the range, such that Prev
+// returns the last key.
+func (iter *Iterator) SeekToLast() {
+	iter.setBlock(iter.endBlock-1, atLast)
+}
+
+// Next returns the next key value pair, skipping tombstones and expired values. Merge
+// operands are returned as is, use NextEntry to distinguish merge operands from values.
+func (iter *Iterator) Next() (types.KV, bool) {
+	return liveValue(iter.NextEntry)
+}
+
+// NextEntry returns the next entry in the SSTable, including tombstones and expired values.
+// Returns false once every entry in the range has been returned, or if reading a block
+// failed, in which case Err() returns the error.
+func (iter *Iterator) NextEntry() (types.KeyValue, bool) {
+	for iter.readBlock() {
+		kv, ok := iter.blockIter.NextEntry()
+		if !ok {
+			// Move to the start of the next block
+			if iter.blockIdx+1 >= iter.endBlock {
+				return types.KeyValue{}, false
+			}
+			iter.setBlock(iter.blockIdx+1, block.NewIterator)
+			continue
+		}
+		if !iter.keyRange.beforeEnd(kv.Key) {
+			// Every following key is beyond the end of the range, remain positioned
+			// before the key such that Prev returns the last key in the range.
+			iter.blockIter.PrevEntry()
+			return types.KeyValue{}, false
+		}
+		// Only the key a seek is positioned at may be excluded by the start of the range
+		if !iter.keyRange.afterStart(kv.Key) {
+			continue
+		}
+		return kv, true
+	}
+	return types.KeyValue{}, false
+}
+
+// Prev returns the previous key value pair, skipping tombstones and expired values. Merge
+// operands are returned as is, use PrevEntry to distinguish merge operands from values.
+func (iter *Iterator) Prev() (types.KV, bool) {
+	return liveValue(iter.PrevEntry)
+}
+
+// PrevEntry returns the previous entry in the SSTable, including tombstones and expired
+// values. Returns false once every entry in the range before the position of the iterator
+// has been returned, or if reading a block failed, in which case Err() returns the error.
+func (iter *Iterator) PrevEntry() (types.KeyValue, bool) {
+	for iter.readBlock() {
+		kv, ok := iter.blockIter.PrevEntry()
+		if !ok {
+			// Move to the end of the previous block
+			if iter.blockIdx <= iter.startBlock {
+				return types.KeyValue{}, false
+			}
+			iter.setBlock(iter.blockIdx-1, atLast)
+			continue
+		}
+		if !iter.keyRange.afterStart(kv.Key) {
+			// Every preceding key is before the start of the range, remain positioned
+			// after the key such that Next returns the first key in the range.
+			iter.blockIter.NextEntry()
+			return types.KeyValue{}, false
+		}
+		if !iter.keyRange.beforeEnd(kv.Key) {
+			continue
+		}
+		return kv, true
+	}
+	return types.KeyValue{}, false
+}
+
+// Err returns the error which stopped the iteration, if any
+func (iter *Iterator) Err() error {
+	return iter.err
+}
+
+// setBlock positions the iterator within the block at the provided index, the block is
+// read and positioned by open on the next call to readBlock().
+func (iter *Iterator) setBlock(i int, open func(b *block.Block) *block.Iterator) {
+	iter.blockIter = nil
+	iter.blockIdx = max(i, iter.startBlock)
+	iter.open = open
+}
+
+// readBlock reads the block at blockIdx if it has not been read, returns false if the block
+// is not within the range of blocks or the read failed.
+func (iter *Iterator) readBlock() bool {
+	if iter.err != nil {
+		return false
+	}
+	if iter.blockIter != nil {
+		return true
+	}
+	if iter.blockIdx < iter.startBlock || iter.blockIdx >= iter.endBlock {
+		return false
+	}
+
+	i := uint64(iter.blockIdx)
+	blocks, err := iter.decoder.ReadBlocks(iter.info, iter.index, Range{Start: i, End: i + 1}, iter.blob)
+	if err != nil {
+		iter.err = fmt.Errorf("while reading block %d of SSTable '%s': %w", i, iter.blob.Id(), err)
+		return false
+	}
+	iter.blockIter = iter.open(&blocks[0])
+	return true
+}
+
+// atLast returns a block iterator positioned after the last key in the block
+func atLast(b *block.Block) *block.Iterator {
+	iter := block.NewIterator(b)
+	iter.SeekToLast()
+	return iter
+}
+
+// liveValue returns the first entry returned by next which is not a tombstone or expired
+func liveValue(next func() (types.KeyValue, bool)) (types.KV, bool) {
+	now := time.Now()
+	for {
+		entry, ok := next()
+		if !ok {
+			return types.KV{}, false
+		}
+		if entry.Value.IsTombstone || entry.Value.IsExpired(now) {
+			continue
+		}
+		return types.KV{
+			Key:   entry.Key,
+			Value: entry.Value.Value,
+		}, true
+	}
+}
+
+// findBlock returns the index of the last block with a first key less than or equal to the
+// key, which is the only block which may contain the key. Returns -1 if the key is before
+// the first key of the first block, or if there are no blocks.
+func findBlock(meta []*flatbuf.BlockMetaT, key []byte) int {
+	i := sort.Search(len(meta), func(i int) bool {
+		return bytes.Compare(meta[i].FirstKey, key) > 0
+	})
+	return i - 1
+}
@@ -0,0 +1,542 @@
+package sstable
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/lsm-go/internal/compress"
+	"github.com/thrawn01/lsm-go/internal/sstable/types"
+)
+
+// countingBlob counts the calls to ReadRange and fails them once failAfter reads
+// have been made, if failAfter is not zero
+type countingBlob struct {
+	mockBlob
+	reads     int
+	failAfter int
+}
+
+func (c *countingBlob) ReadRange(r Range) ([]byte, error) {
+	c.reads++
+	if c.failAfter != 0 && c.reads > c.failAfter {
+		return nil, errors.New("read failed")
+	}
+	return c.mockBlob.ReadRange(r)
+}
+
+// buildIterTable builds an SSTable of keys 'key00' to 'key<n-1>' with every odd key deleted,
+// each key is in its own block
+func buildIterTable(t *testing.T, n int) (*Decoder, *Info, *Index, *countingBlob) {
+	t.Helper()
+	conf := Config{
+		BlockSize:        30,
+		MinFilterKeys:    2,
+		FilterBitsPerKey: 10,
+		Compression:      compress.CodecNone,
+	}
+	builder := NewBuilder(conf)
+	for i := 0; i < n; i++ {
+		kv := types.KeyValue{
+			Key:   []byte(fmt.Sprintf("key%02d", i)),
+			Value: types.Value{Value: []byte(fmt.Sprintf("value-%02d-padded-beyond-block-size", i))},
+			Seq:   uint64(i + 1),
+		}
+		if i%2 == 1 {
+			kv.Value = types.Value{IsTombstone: true}
+		}
+		require.NoError(t, builder.AddEntry(kv))
+	}
+	table := builder.Build()
+
+	blob := &countingBlob{mockBlob: mockBlob{data: table.Data}}
+	decoder := &Decoder{Config: conf}
+	info, err := decoder.ReadInfo(blob)
+	require.NoError(t, err)
+	index, err := decoder.ReadIndex(info, blob)
+	require.NoError(t, err)
+	require.Len(t, index.AsFlatBuf().BlockMeta, n)
+	blob.reads = 0
+	return decoder, info, index, blob
+}
+
+func TestIterator(t *testing.T) {
+	decoder, info, index, blob := buildIterTable(t, 6)
+
+	iter := NewIterator(decoder, info, index, blob)
+	assert.Equal(t, 0, blob.reads, "blocks are not read until the iterator advances")
+
+	var keys []string
+	for {
+		kv, ok := iter.Next()
+		if !ok {
+			break
+		}
+		keys = append(keys, string(kv.Key))
+	}
+	require.NoError(t, iter.Err())
+	assert.Equal(t, []string{"key00", "key02", "key04"}, keys)
+	assert.Equal(t, 6, blob.reads, "each block is read once")
+
+	// NextEntry includes tombstones
+	iter = NewIterator(decoder, info, index, blob)
+	var entries []types.KeyValue
+	for {
+		kv, ok := iter.NextEntry()
+		if !ok {
+			break
+		}
+		entries = append(entries, kv)
+	}
+	require.NoError(t, iter.Err())
+	require.Len(t, entries, 6)
+	assert.Equal(t, []byte("key01"), entries[1].Key)
+	assert.True(t, entries[1].Value.IsTombstone)
+	assert.Equal(t, uint64(2), entries[1].Seq)
+
+	// Reading a single key only reads a single block
+	blob.reads = 0
+	iter = NewIterator(decoder, info, index, blob)
+	kv, ok := iter.Next()
+	require.True(t, ok)
+	assert.Equal(t, []byte("key00"), kv.Key)
+	assert.Equal(t, 1, blob.reads)
+}
+
+func TestIteratorSeek(t *testing.T) {
+	decoder, info, index, blob := buildIterTable(t, 6)
+
+	for _, tt := range []struct {
+		name     string
+		seek     string
+		expected []string
+	}{
+		{name: "BeforeFirst", seek: "a", expected: []string{"key00", "key02", "key04"}},
+		{name: "ExactFirst", seek: "key00", expected: []string{"key00", "key02", "key04"}},
+		{name: "Exact", seek: "key02", expected: []string{"key02", "key04"}},
+		{name: "Between", seek: "key02a", expected: []string{"key04"}},
+		{name: "Tombstone", seek: "key03", expected: []string{"key04"}},
+		{name: "Last", seek: "key05", expected: nil},
+		{name: "AfterLast", seek: "zzz", expected: nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			iter := NewIterator(decoder, info, index, blob)
+			iter.Seek([]byte(tt.seek))
+
+			var keys []string
+			for {
+				kv, ok := iter.Next()
+				if !ok {
+					break
+				}
+				keys = append(keys, string(kv.Key))
+			}
+			require.NoError(t, iter.Err())
+			assert.Equal(t, tt.expected, keys)
+		})
+	}
+
+	// Seek only reads the blocks from the block which may contain the key
+	blob.reads = 0
+	iter := NewIterator(decoder, info, index, blob)
+	iter.Seek([]byte("key04"))
+	kv, ok := iter.Next()
+	require.True(t, ok)
+	assert.Equal(t, []byte("key04"), kv.Key)
+	assert.Equal(t, 1, blob.reads)
+
+	// Seek backwards after iterating
+	iter.Seek([]byte("key01"))
+	entry, ok := iter.NextEntry()
+	require.True(t, ok)
+	assert.Equal(t, []byte("key01"), entry.Key)
+}
+
+func TestIteratorReadError(t *testing.T) {
+	decoder, info, index, blob := buildIterTable(t, 6)
+	blob.failAfter = 2
+
+	iter := NewIterator(decoder, info, index, blob)
+	var keys []string
+	for {
+		kv, ok := iter.NextEntry()
+		if !ok {
+			break
+		}
+		keys = append(keys, string(kv.Key))
+	}
+	assert.Equal(t, []string{"key00", "key01"}, keys)
+	require.Error(t, iter.Err())
+	assert.Contains(t, iter.Err().Error(), "while reading block 2 of SSTable '1234'")
+
+	// The iterator remains stopped
+	_, ok := iter.Next()
+	assert.False(t, ok)
+}
+
+// buildRangeTable builds an SSTable of the provided keys with two keys in each block
+func buildRangeTable(t *testing.T, keys ...string) (*Reader, *countingBlob) {
+	t.Helper()
+	conf := Config{
+		BlockSize:        70,
+		MinFilterKeys:    2,
+		FilterBitsPerKey: 10,
+		Compression:      compress.CodecNone,
+	}
+	builder := NewBuilder(conf)
+	for _, key := range keys {
+		require.NoError(t, builder.Add([]byte(key), []byte("value")))
+	}
+	table := builder.Build()
+
+	blob := &countingBlob{mockBlob: mockBlob{data: table.Data}}
+	reader, err := NewReader(&Decoder{Config: conf}, blob)
+	require.NoError(t, err)
+	require.Len(t, reader.meta, (len(keys)+1)/2)
+	return reader, blob
+}
+
+func TestRangeIterator(t *testing.T) {
+	var keys []string
+	for i := 0; i < 20; i++ {
+		keys = append(keys, fmt.Sprintf("k%02d", i))
+	}
+	reader, blob := buildRangeTable(t, keys...)
+
+	for _, tt := range []struct {
+		name     string
+		r        KeyRange
+		expected []string
+		// reads is the number of blocks read
+		reads int
+	}{
+		{
+			name:     "Unbounded",
+			r:        KeyRange{},
+			expected: keys,
+			reads:    10,
+		},
+		{
+			name:     "StartInclusiveEndExclusive",
+			r:        KeyRange{Start: []byte("k04"), End: []byte("k08")},
+			expected: []string{"k04", "k05", "k06", "k07"},
+			reads:    2,
+		},
+		{
+			name:     "EndInclusive",
+			r:        KeyRange{Start: []byte("k04"), End: []byte("k08"), EndInclusive: true},
+			expected: []string{"k04", "k05", "k06", "k07", "k08"},
+			reads:    3,
+		},
+		{
+			name:     "StartExclusive",
+			r:        KeyRange{Start: []byte("k04"), StartExclusive: true, End: []byte("k08")},
+			expected: []string{"k05", "k06", "k07"},
+			reads:    2,
+		},
+		{
+			name:     "StartExclusiveEndInclusive",
+			r:        KeyRange{Start: []byte("k05"), StartExclusive: true, End: []byte("k09"), EndInclusive: true},
+			expected: []string{"k06", "k07", "k08", "k09"},
+			reads:    3,
+		},
+		{
+			name:     "NoStart",
+			r:        KeyRange{End: []byte("k03")},
+			expected: []string{"k00", "k01", "k02"},
+			reads:    2,
+		},
+		{
+			name:     "NoEnd",
+			r:        KeyRange{Start: []byte("k17")},
+			expected: []string{"k17", "k18", "k19"},
+			reads:    2,
+		},
+		{
+			name:     "BetweenKeys",
+			r:        KeyRange{Start: []byte("k035"), End: []byte("k036")},
+			expected: nil,
+			reads:    1,
+		},
+		{
+			name:     "BeforeFirst",
+			r:        KeyRange{Start: []byte("a"), End: []byte("k00")},
+			expected: nil,
+			reads:    0,
+		},
+		{
+			name:     "AfterLast",
+			r:        KeyRange{Start: []byte("z")},
+			expected: nil,
+			reads:    1,
+		},
+		{
+			name:     "Empty",
+			r:        KeyRange{Start: []byte("k08"), End: []byte("k04")},
+			expected: nil,
+			reads:    0,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			blob.reads = 0
+			iter := reader.Scan(tt.r)
+			var actual []string
+			for {
+				kv, ok := iter.Next()
+				if !ok {
+					break
+				}
+				actual = append(actual, string(kv.Key))
+			}
+			require.NoError(t, iter.Err())
+			assert.Equal(t, tt.expected, actual)
+			assert.Equal(t, tt.reads, blob.reads)
+
+			// The iterator remains at the end of the range
+			_, ok := iter.NextEntry()
+			assert.False(t, ok)
+		})
+	}
+
+	// Seeking before the start of the range positions the iterator at the start
+	iter := reader.Scan(KeyRange{Start: []byte("k10"), StartExclusive: true, End: []byte("k12")})
+	iter.Seek([]byte("k00"))
+	kv, ok := iter.Next()
+	require.True(t, ok)
+	assert.Equal(t, []byte("k11"), kv.Key)
+	_, ok = iter.Next()
+	assert.False(t, ok)
+}
+
+func TestScanPrefix(t *testing.T) {
+	reader, blob := buildRangeTable(t,
+		"user/122/a", "user/123/", "user/123/a", "user/123/b", "user/1234/a", "user/124/a")
+
+	blob.reads = 0
+	iter := reader.ScanPrefix([]byte("user/123/"))
+	var actual []string
+	for {
+		kv, ok := iter.Next()
+		if !ok {
+			break
+		}
+		actual = append(actual, string(kv.Key))
+	}
+	require.NoError(t, iter.Err())
+	assert.Equal(t, []string{"user/123/", "user/123/a", "user/123/b"}, actual)
+	assert.Equal(t, 2, blob.reads)
+}
+
+func TestPrefixRange(t *testing.T) {
+	for _, tt := range []struct {
+		prefix []byte
+		end    []byte
+	}{
+		{prefix: []byte("user/123/"), end: []byte("user/1230")},
+		{prefix: []byte("ab\xff"), end: []byte("ac")},
+		{prefix: []byte("a\xff\xff"), end: []byte("b")},
+		{prefix: []byte("\xff\xff"), end: nil},
+		{prefix: []byte{}, end: nil},
+	} {
+		r := PrefixRange(tt.prefix)
+		assert.Equal(t, tt.prefix, r.Start)
+		assert.Equal(t, tt.end, r.End, "prefix %q", tt.prefix)
+		assert.False(t, r.StartExclusive)
+		assert.False(t, r.EndInclusive)
+	}
+
+	r := PrefixRange([]byte("ab\xff"))
+	assert.True(t, r.Contains([]byte("ab\xff")))
+	assert.True(t, r.Contains([]byte("ab\xff\xff\xff")))
+	assert.False(t, r.Contains([]byte("ab")))
+	assert.False(t, r.Contains([]byte("ac")))
+}
+
+// prevKeys returns the keys returned by Prev until the iterator is exhausted
+func prevKeys(t *testing.T, iter *Iterator) []string {
+	t.Helper()
+	var keys []string
+	for {
+		kv, ok := iter.Prev()
+		if !ok {
+			break
+		}
+		keys = append(keys, string(kv.Key))
+	}
+	require.NoError(t, iter.Err())
+	return keys
+}
+
+func TestIteratorPrev(t *testing.T) {
+	decoder, info, index, blob := buildIterTable(t, 6)
+
+	// Prev walks the blocks backwards, skipping tombstones
+	iter := NewIterator(decoder, info, index, blob)
+	iter.SeekToLast()
+	assert.Equal(t, []string{"key04", "key02", "key00"}, prevKeys(t, iter))
+	assert.Equal(t, 6, blob.reads, "each block is read once")
+
+	// PrevEntry includes tombstones
+	iter.SeekToLast()
+	var entries []types.KeyValue
+	for {
+		kv, ok := iter.PrevEntry()
+		if !ok {
+			break
+		}
+		entries = append(entries, kv)
+	}
+	require.NoError(t, iter.Err())
+	require.Len(t, entries, 6)
+	assert.Equal(t, []byte("key05"), entries[0].Key)
+	assert.True(t, entries[0].Value.IsTombstone)
+
+	// Prev after Next returns the same key, across blocks
+	iter = NewIterator(decoder, info, index, blob)
+	for _, expected := range []string{"key00", "key02"} {
+		kv, ok := iter.Next()
+		require.True(t, ok)
+		assert.Equal(t, expected, string(kv.Key))
+	}
+	assert.Equal(t, []string{"key02", "key00"}, prevKeys(t, iter))
+	kv, ok := iter.Next()
+	require.True(t, ok)
+	assert.Equal(t, []byte("key00"), kv.Key)
+
+	// Prev after every key has been returned by Next returns the last key
+	iter = NewIterator(decoder, info, index, blob)
+	for {
+		if _, ok := iter.Next(); !ok {
+			break
+		}
+	}
+	kv, ok = iter.Prev()
+	require.True(t, ok)
+	assert.Equal(t, []byte("key04"), kv.Key)
+}
+
+func TestIteratorSeekForPrev(t *testing.T) {
+	decoder, info, index, blob := buildIterTable(t, 6)
+
+	for _, tt := range []struct {
+		name     string
+		seek     string
+		expected []string
+	}{
+		{name: "BeforeFirst", seek: "a", expected: nil},
+		{name: "ExactFirst", seek: "key00", expected: []string{"key00"}},
+		{name: "Exact", seek: "key02", expected: []string{"key02", "key00"}},
+		{name: "Between", seek: "key02a", expected: []string{"key02", "key00"}},
+		{name: "Tombstone", seek: "key03", expected: []string{"key02", "key00"}},
+		{name: "AfterLast", seek: "zzz", expected: []string{"key04", "key02", "key00"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			iter := NewIterator(decoder, info, index, blob)
+			iter.SeekForPrev([]byte(tt.seek))
+			assert.Equal(t, tt.expected, prevKeys(t, iter))
+		})
+	}
+
+	// SeekForPrev only reads the block which may contain the key
+	blob.reads = 0
+	iter := NewIterator(decoder, info, index, blob)
+	iter.SeekForPrev([]byte("key04"))
+	kv, ok := iter.Prev()
+	require.True(t, ok)
+	assert.Equal(t, []byte("key04"), kv.Key)
+	assert.Equal(t, 1, blob.reads)
+
+	// Next after SeekForPrev returns the key after the seek key
+	iter.SeekForPrev([]byte("key02"))
+	entry, ok := iter.NextEntry()
+	require.True(t, ok)
+	assert.Equal(t, []byte("key03"), entry.Key)
+}
+
+func TestRangeIteratorPrev(t *testing.T) {
+	var keys []string
+	for i := 0; i < 20; i++ {
+		keys = append(keys, fmt.Sprintf("k%02d", i))
+	}
+	reader, blob := buildRangeTable(t, keys...)
+
+	for _, tt := range []struct {
+		name     string
+		r        KeyRange
+		expected []string
+		// reads is the number of blocks read
+		reads int
+	}{
+		{
+			name:     "StartInclusiveEndExclusive",
+			r:        KeyRange{Start: []byte("k04"), End: []byte("k08")},
+			expected: []string{"k07", "k06", "k05", "k04"},
+			reads:    2,
+		},
+		{
+			name:     "StartExclusiveEndInclusive",
+			r:        KeyRange{Start: []byte("k05"), StartExclusive: true, End: []byte("k09"), EndInclusive: true},
+			expected: []string{"k09", "k08", "k07", "k06"},
+			reads:    3,
+		},
+		{
+			name:     "NoEnd",
+			r:        KeyRange{Start: []byte("k17")},
+			expected: []string{"k19", "k18", "k17"},
+			reads:    2,
+		},
+		{
+			name:     "BetweenKeys",
+			r:        KeyRange{Start: []byte("k035"), End: []byte("k036")},
+			expected: nil,
+			reads:    1,
+		},
+		{
+			name:     "Empty",
+			r:        KeyRange{Start: []byte("k08"), End: []byte("k04")},
+			expected: nil,
+			reads:    0,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			blob.reads = 0
+			iter := reader.Scan(tt.r)
+			iter.SeekToLast()
+			assert.Equal(t, tt.expected, prevKeys(t, iter))
+			assert.Equal(t, tt.reads, blob.reads)
+
+			// The iterator remains at the start of the range
+			_, ok := iter.PrevEntry()
+			assert.False(t, ok)
+			if len(tt.expected) != 0 {
+				kv, ok := iter.Next()
+				require.True(t, ok)
+				assert.Equal(t, tt.expected[len(tt.expected)-1], string(kv.Key))
+			}
+		})
+	}
+
+	// Prev after Next reaches the end of the range returns the last key in the range
+	iter := reader.Scan(KeyRange{Start: []byte("k04"), End: []byte("k06")})
+	for {
+		if _, ok := iter.Next(); !ok {
+			break
+		}
+	}
+	assert.Equal(t, []string{"k05", "k04"}, prevKeys(t, iter))
+
+	// Seeking outside the range positions the iterator at the start or end of the range
+	iter.SeekForPrev([]byte("k10"))
+	assert.Equal(t, []string{"k05", "k04"}, prevKeys(t, iter))
+	iter.Seek([]byte("k10"))
+	_, ok := iter.Next()
+	assert.False(t, ok)
+	assert.Equal(t, []string{"k05", "k04"}, prevKeys(t, iter))
+	iter.SeekForPrev([]byte("k00"))
+	assert.Nil(t, prevKeys(t, iter))
+	kv, ok := iter.Next()
+	require.True(t, ok)
+	assert.Equal(t, []byte("k04"), kv.Key)
+}
@@ -0,0 +1,107 @@
+package sstable
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/thrawn01/lsm-go/internal/flatbuf"
+	"github.com/thrawn01/lsm-go/internal/sstable/block"
+	"github.com/thrawn01/lsm-go/internal/sstable/bloom"
+	"github.com/thrawn01/lsm-go/internal/sstable/types"
+)
+
+// Reader reads keys from an encoded SSTable. The Info, Index and bloom.Filter are read
+// once when the Reader is opened, such that each lookup only reads the block which may
+// contain the key.
+type Reader struct {
+	decoder *Decoder
+	blob    ReadOnlyBlob
+	info    *Info
+	index   *Index
+	meta    []*flatbuf.BlockMetaT
+	// filter is nil if the SSTable has too few keys to have a bloom filter
+	filter *bloom.Filter
+}
+
+// NewReader opens the SSTable in the provided blob by reading the Info, Index and
+// bloom.Filter of the SSTable.
+func NewReader(d *Decoder, b ReadOnlyBlob) (*Reader, error) {
+	info, err := d.ReadInfo(b)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := d.ReadIndex(info, b)
+	if err != nil {
+		return nil, fmt.Errorf("while reading SSTable '%s': %w", b.Id(), err)
+	}
+
+	filter, err := d.ReadBloom(info, b)
+	if err != nil {
+		return nil, fmt.Errorf("while reading SSTable '%s': %w", b.Id(), err)
+	}
+
+	r := &Reader{
+		decoder: d,
+		blob:    b,
+		info:    info,
+		index:   index,
+		filter:  filter,
+	}
+	if index != nil {
+		r.meta = index.AsFlatBuf().BlockMeta
+	}
+	return r, nil
+}
+
+// Info returns the Info of the SSTable
+func (r *Reader) Info() *Info {
+	return r.info
+}
+
+// Get returns the entry of the key in the SSTable, returns false if the key is not in the
+// SSTable. If the key was deleted, the entry returned is a tombstone, such that callers can
+// stop looking for the key in older tables. Expired values and merge operands are returned
+// as is.
+//
+// If the bloom filter reports the key is not in the SSTable no blocks are read, else only
+// the block which may contain the key is read.
+func (r *Reader) Get(key []byte) (types.KeyValue, bool, error) {
+	if r.filter != nil && !r.filter.HasKey(key) {
+		return types.KeyValue{}, false, nil
+	}
+
+	i := findBlock(r.meta, key)
+	if i < 0 {
+		// The key is before the first key of the SSTable, or the SSTable has no blocks
+		return types.KeyValue{}, false, nil
+	}
+
+	blocks, err := r.decoder.ReadBlocks(r.info, r.index, Range{Start: uint64(i), End: uint64(i + 1)}, r.blob)
+	if err != nil {
+		return types.KeyValue{}, false, fmt.Errorf("while reading block %d of SSTable '%s': %w",
+			i, r.blob.Id(), err)
+	}
+
+	kv, ok := block.NewIteratorAtKey(&blocks[0], key).NextEntry()
+	if !ok || !bytes.Equal(kv.Key, key) {
+		return types.KeyValue{}, false, nil
+	}
+	return kv, true, nil
+}
+
+// Iterator returns an Iterator positioned at the first key of the SSTable
+func (r *Reader) Iterator() *Iterator {
+	return NewIterator(r.decoder, r.info, r.index, r.blob)
+}
+
+// Scan returns an Iterator over the keys of the SSTable within the range, only the blocks
+// which may contain keys in the range are read.
+func (r *Reader) Scan(keyRange KeyRange) *Iterator {
+	return NewRangeIterator(r.decoder, r.info, r.index, r.blob, keyRange)
+}
+
+// ScanPrefix returns an Iterator over the keys of the SSTable which begin with the prefix
+func (r *Reader) ScanPrefix(prefix []byte) *Iterator {
+	return r.Scan(PrefixRange(prefix))
+}
@@ -0,0 +1,109 @@
+package sstable
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/lsm-go/internal/compress"
+)
+
+func TestReaderGet(t *testing.T) {
+	decoder, _, _, blob := buildIterTable(t, 6)
+
+	reader, err := NewReader(decoder, blob)
+	require.NoError(t, err)
+	require.NotNil(t, reader.filter)
+	assert.Equal(t, []byte("key00"), reader.Info().FirstKey)
+
+	for _, tt := range []struct {
+		name      string
+		key       string
+		found     bool
+		tombstone bool
+		// reads is the number of blocks read by Get
+		reads int
+	}{
+		{name: "First", key: "key00", found: true, reads: 1},
+		{name: "Middle", key: "key02", found: true, reads: 1},
+		{name: "Last", key: "key05", found: true, tombstone: true, reads: 1},
+		{name: "Tombstone", key: "key03", found: true, tombstone: true, reads: 1},
+		{name: "FilteredByBloom", key: "key02a", reads: 0},
+		{name: "BeforeFirst", key: "a", reads: 0},
+		{name: "AfterLast", key: "zzz", reads: 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			blob.reads = 0
+			kv, found, err := reader.Get([]byte(tt.key))
+			require.NoError(t, err)
+			assert.Equal(t, tt.found, found)
+			assert.Equal(t, tt.reads, blob.reads)
+			if !tt.found {
+				return
+			}
+			assert.Equal(t, []byte(tt.key), kv.Key)
+			assert.Equal(t, tt.tombstone, kv.Value.IsTombstone)
+			if !tt.tombstone {
+				assert.Equal(t, []byte("value-"+tt.key[3:]+"-padded-beyond-block-size"), kv.Value.Value)
+			}
+		})
+	}
+
+	// The iterator uses the cached Info and Index
+	blob.reads = 0
+	kv, ok := reader.Iterator().Next()
+	require.True(t, ok)
+	assert.Equal(t, []byte("key00"), kv.Key)
+	assert.Equal(t, 1, blob.reads)
+}
+
+func TestReaderGetWithoutBloom(t *testing.T) {
+	conf := Config{
+		BlockSize:        1024,
+		MinFilterKeys:    10,
+		FilterBitsPerKey: 10,
+		Compression:      compress.CodecNone,
+	}
+	builder := NewBuilder(conf)
+	require.NoError(t, builder.Add([]byte("key1"), []byte("value1")))
+	require.NoError(t, builder.Add([]byte("key3"), []byte("value3")))
+	table := builder.Build()
+	require.Nil(t, table.Bloom)
+
+	blob := &countingBlob{mockBlob: mockBlob{data: table.Data}}
+	reader, err := NewReader(&Decoder{Config: conf}, blob)
+	require.NoError(t, err)
+
+	// Without a bloom filter, the block which may contain the key is read
+	blob.reads = 0
+	_, found, err := reader.Get([]byte("key2"))
+	require.NoError(t, err)
+	assert.False(t, found)
+	assert.Equal(t, 1, blob.reads)
+
+	kv, found, err := reader.Get([]byte("key3"))
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, []byte("value3"), kv.Value.Value)
+}
+
+func TestReaderErrors(t *testing.T) {
+	decoder, _, _, blob := buildIterTable(t, 6)
+
+	// A failure to read the bloom.Filter, which is read after the Info and Index, fails
+	// to open the reader
+	blob.failAfter = 3
+	_, err := NewReader(decoder, blob)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "while reading SSTable '1234'")
+
+	blob.reads, blob.failAfter = 0, 0
+	reader, err := NewReader(decoder, blob)
+	require.NoError(t, err)
+
+	// Every read fails
+	blob.reads, blob.failAfter = 0, -1
+	_, _, err = reader.Get([]byte("key02"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "while reading block 2 of SSTable '1234'")
+}
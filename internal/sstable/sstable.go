@@ -1,6 +1,8 @@
 package sstable
 
 import (
+	"bytes"
+
 	"github.com/thrawn01/lsm-go/internal/compress"
 	"github.com/thrawn01/lsm-go/internal/flatbuf"
 	"github.com/thrawn01/lsm-go/internal/sstable/bloom"
@@ -26,6 +28,15 @@ type Info struct {
 
 	// the codec used to compress/decompress SSTable before writing/reading from object storage
 	CompressionCodec compress.Codec
+
+	// the lowest sequence number of any entry in the SSTable
+	MinSeq uint64
+
+	// the highest sequence number of any entry in the SSTable
+	MaxSeq uint64
+
+	// the epoch of the writer which wrote the SSTable
+	WriterEpoch uint64
 }
 
 func (s *Info) Clone() *Info {
@@ -41,6 +52,9 @@ func (s *Info) Clone() *Info {
 		FilterOffset:     s.FilterOffset,
 		FilterLen:        s.FilterLen,
 		CompressionCodec: s.CompressionCodec,
+		MinSeq:           s.MinSeq,
+		MaxSeq:           s.MaxSeq,
+		WriterEpoch:      s.WriterEpoch,
 	}
 }
 
@@ -52,6 +66,59 @@ type Range struct {
 	End uint64
 }
 
+// KeyRange is a range of keys in an SSTable. The zero value of each bound includes the start
+// key and excludes the end key, such that KeyRange{Start: a, End: b} is the range [a, b).
+type KeyRange struct {
+	// Start is the lower bound of the range, nil if the range has no lower bound
+	Start []byte
+
+	// StartExclusive if true excludes Start from the range
+	StartExclusive bool
+
+	// End is the upper bound of the range, nil if the range has no upper bound
+	End []byte
+
+	// EndInclusive if true includes End in the range
+	EndInclusive bool
+}
+
+// PrefixRange returns the range of every key which begins with the prefix
+func PrefixRange(prefix []byte) KeyRange {
+	r := KeyRange{Start: prefix}
+	// The end of the range is the prefix up to the last byte which is not 0xff, with that
+	// byte incremented. A prefix of only 0xff bytes has no upper bound.
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] != 0xff {
+			r.End = append(append([]byte(nil), prefix[:i]...), prefix[i]+1)
+			break
+		}
+	}
+	return r
+}
+
+// Contains returns true if the key is within the range
+func (r KeyRange) Contains(key []byte) bool {
+	return r.afterStart(key) && r.beforeEnd(key)
+}
+
+// afterStart returns true if the key is not below the lower bound of the range
+func (r KeyRange) afterStart(key []byte) bool {
+	if r.Start == nil {
+		return true
+	}
+	c := bytes.Compare(key, r.Start)
+	return c > 0 || (c == 0 && !r.StartExclusive)
+}
+
+// beforeEnd returns true if the key is not above the upper bound of the range
+func (r KeyRange) beforeEnd(key []byte) bool {
+	if r.End == nil {
+		return true
+	}
+	c := bytes.Compare(key, r.End)
+	return c < 0 || (c == 0 && r.EndInclusive)
+}
+
 type ReadOnlyBlob interface {
 	Len() (uint64, error)
 
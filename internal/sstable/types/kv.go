@@ -2,6 +2,7 @@ package types
 
 import (
 	"encoding/binary"
+	"time"
 )
 
 // KV Represents a key-value pair known not to be a tombstone.
@@ -14,35 +15,36 @@ type KV struct {
 type KeyValue struct {
 	Key   []byte
 	Value Value
+	// Seq is the sequence number of the write which produced this entry
+	Seq uint64
 }
 
 // Value Represents a value that may be a tombstone.
 type Value struct {
 	Value       []byte
 	IsTombstone bool
+	// IsMerge is true if Value is a merge operand which must be combined with
+	// the older values of the key using a MergeOperator.
+	IsMerge bool
+	// ExpireAt is the unix time in milliseconds at which the value expires,
+	// zero if the value never expires. A tombstone never expires.
+	ExpireAt int64
 }
 
-// DecodeValue decodes a value from a byte slice.
-// If first byte is 1, then return tombstone else return with value
-func DecodeValue(b []byte) Value {
-	if b[0] == 1 {
-		return Value{IsTombstone: true}
-	}
-
-	return Value{
-		Value:       b[1:],
-		IsTombstone: false,
-	}
+// MergeOperator combines a merge operand with the existing value of a key, such that values
+// can be updated without first reading the existing value. Merge must be associative, as
+// operands are combined with each other when the existing value is not yet known, in which
+// case existing is the older operand.
+type MergeOperator interface {
+	// Merge returns the result of applying the operand to the existing value of the key.
+	// existing is nil if the key has no value.
+	Merge(key, existing, operand []byte) ([]byte, error)
 }
 
-// Encode encodes the value into a byte slice.
-// If it is a tombstone return 1 (indicating tombstone) as the only byte
-// if it is not a tombstone the value is stored from second byte onwards
-func (v Value) Encode() []byte {
-	if v.IsTombstone {
-		return []byte{1}
-	}
-	return append([]byte{0}, v.Value...)
+// IsExpired returns true if the value has an expiration time at or before now.
+// An expired value is treated as if it were a tombstone.
+func (v Value) IsExpired(now time.Time) bool {
+	return v.ExpireAt != 0 && !v.IsTombstone && v.ExpireAt <= now.UnixMilli()
 }
 
 func (v Value) Size() int64 {
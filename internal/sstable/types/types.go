@@ -5,5 +5,14 @@ import "math"
 const (
 	SizeOfUint16 = 2
 	SizeOfUint32 = 4
+	SizeOfUint64 = 8
 	Tombstone    = math.MaxUint32
 )
+
+// Flags of an encoded KeyValue
+const (
+	// FlagExpires is set if the KeyValue is followed by the time at which the value expires
+	FlagExpires = 1 << 0
+	// FlagMerge is set if the value is a merge operand, see MergeOperator
+	FlagMerge = 1 << 1
+)
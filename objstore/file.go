@@ -0,0 +1,280 @@
+package objstore
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// tmpDir is the directory within the root of a FileStore where objects are written
+// before they are renamed into place.
+const tmpDir = ".tmp"
+
+// FileStore is an object store which stores each object as a file in a directory on the
+// local filesystem. The path of the object is the slash separated path of the file
+// relative to the root directory.
+//
+// Objects are written to a temporary file which is synced then renamed into place, such
+// that an object is never observed partially written, even after a crash. The rename is
+// only durable once Sync returns.
+type FileStore struct {
+	root string
+	mu   sync.Mutex
+	// dirty are the directories which contain renamed or removed files since the last Sync
+	dirty map[string]struct{}
+}
+
+var _ Bucket = (*FileStore)(nil)
+
+// NewFileStore returns a FileStore which stores objects in the provided directory,
+// creating the directory if it does not exist.
+func NewFileStore(root string) (*FileStore, error) {
+	root = filepath.Clean(root)
+	if err := os.MkdirAll(filepath.Join(root, tmpDir), 0o755); err != nil {
+		return nil, fmt.Errorf("while creating directory '%s': %w", root, err)
+	}
+	return &FileStore{
+		root:  root,
+		dirty: make(map[string]struct{}),
+	}, nil
+}
+
+// Put writes data as an object at the provided path, replacing any existing object
+func (s *FileStore) Put(p string, data []byte) error {
+	return s.put(p, data, func(tmp, name string) error {
+		return os.Rename(tmp, name)
+	})
+}
+
+// PutIfNotExists writes data as an object at the provided path only if no object exists
+// at the path, returns ErrAlreadyExists if an object exists at the path. The object is
+// linked into place, which fails if the file exists, such that only one of many concurrent
+// writers to the same path succeeds.
+func (s *FileStore) PutIfNotExists(p string, data []byte) error {
+	return s.put(p, data, func(tmp, name string) error {
+		err := os.Link(tmp, name)
+		if errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("%w: '%s'", ErrAlreadyExists, p)
+		}
+		return err
+	})
+}
+
+// put writes the data to a temporary file, then calls place to move the file to the
+// path of the object.
+func (s *FileStore) put(p string, data []byte, place func(tmp, name string) error) error {
+	if err := s.checkPath(p); err != nil {
+		return err
+	}
+	name := s.filename(p)
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return fmt.Errorf("while creating directory for '%s': %w", p, err)
+	}
+
+	f, err := os.CreateTemp(filepath.Join(s.root, tmpDir), "object-*")
+	if err != nil {
+		return fmt.Errorf("while creating temp file for '%s': %w", p, err)
+	}
+	tmp := f.Name()
+	defer func() { _ = os.Remove(tmp) }()
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("while writing '%s': %w", p, err)
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("while syncing '%s': %w", p, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("while closing '%s': %w", p, err)
+	}
+
+	if err := place(tmp, name); err != nil {
+		if errors.Is(err, ErrAlreadyExists) {
+			return err
+		}
+		return fmt.Errorf("while moving '%s' into place: %w", p, err)
+	}
+	s.markDirty(filepath.Dir(name))
+	return nil
+}
+
+// Get returns the contents of the object at the provided path
+func (s *FileStore) Get(p string) ([]byte, error) {
+	if err := s.checkPath(p); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(s.filename(p))
+	if err != nil {
+		return nil, s.wrapErr(p, err)
+	}
+	return data, nil
+}
+
+// GetRange returns the bytes of the object within the range, returns ErrInvalidRange
+// if the range is not within the bounds of the object.
+func (s *FileStore) GetRange(p string, r Range) ([]byte, error) {
+	if err := s.checkPath(p); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(s.filename(p))
+	if err != nil {
+		return nil, s.wrapErr(p, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, s.wrapErr(p, err)
+	}
+	if err := checkRange(p, r, uint64(info.Size())); err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, r.End-r.Start)
+	if _, err := f.ReadAt(data, int64(r.Start)); err != nil {
+		return nil, s.wrapErr(p, err)
+	}
+	return data, nil
+}
+
+// Head returns the size and modification time of the file of the object
+func (s *FileStore) Head(p string) (ObjectInfo, error) {
+	if err := s.checkPath(p); err != nil {
+		return ObjectInfo{}, err
+	}
+	info, err := os.Stat(s.filename(p))
+	if err != nil {
+		return ObjectInfo{}, s.wrapErr(p, err)
+	}
+	// A directory holds the objects below it, but is not itself an object
+	if info.IsDir() {
+		return ObjectInfo{}, fmt.Errorf("%w: '%s'", ErrNotFound, p)
+	}
+	return ObjectInfo{Size: uint64(info.Size()), LastModified: info.ModTime()}, nil
+}
+
+// List returns the paths of all the objects which begin with the provided prefix in
+// lexicographical order
+func (s *FileStore) List(prefix string) ([]string, error) {
+	// Only walk the directory which contains every path with the prefix
+	dir := s.root
+	if i := strings.LastIndex(prefix, "/"); i != -1 {
+		if err := validatePath(prefix[:i]); err != nil {
+			return nil, fmt.Errorf("%w: prefix '%s'", err, prefix)
+		}
+		dir = filepath.Join(s.root, filepath.FromSlash(prefix[:i]))
+	}
+
+	var paths []string
+	err := filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		rel, err := filepath.Rel(s.root, name)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if rel == tmpDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(rel, prefix) {
+			paths = append(paths, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("while listing '%s': %w", prefix, err)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Delete removes the object at the provided path. Deleting an object which does not
+// exist is not an error.
+func (s *FileStore) Delete(p string) error {
+	if err := s.checkPath(p); err != nil {
+		return err
+	}
+	name := s.filename(p)
+	if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("while deleting '%s': %w", p, err)
+	}
+	s.markDirty(filepath.Dir(name))
+	return nil
+}
+
+// Sync ensures all previously written and deleted objects are durable by syncing each
+// directory modified since the last call to Sync.
+func (s *FileStore) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for dir := range s.dirty {
+		if err := syncDir(dir); err != nil {
+			return fmt.Errorf("while syncing directory '%s': %w", dir, err)
+		}
+		delete(s.dirty, dir)
+	}
+	return nil
+}
+
+func (s *FileStore) markDirty(dir string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Directories created for the object must also be synced in their parent
+	for ; dir != s.root && strings.HasPrefix(dir, s.root); dir = filepath.Dir(dir) {
+		s.dirty[dir] = struct{}{}
+	}
+	s.dirty[s.root] = struct{}{}
+}
+
+// checkPath returns ErrInvalidPath if p is not a valid object path
+func (s *FileStore) checkPath(p string) error {
+	if err := validatePath(p); err != nil {
+		return fmt.Errorf("%w: '%s'", err, p)
+	}
+	if p == tmpDir || strings.HasPrefix(p, tmpDir+"/") {
+		return fmt.Errorf("%w: '%s' is reserved", ErrInvalidPath, tmpDir)
+	}
+	return nil
+}
+
+func (s *FileStore) filename(p string) string {
+	return filepath.Join(s.root, filepath.FromSlash(path.Clean(p)))
+}
+
+// wrapErr returns ErrNotFound if err indicates the object does not exist
+func (s *FileStore) wrapErr(p string, err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: '%s'", ErrNotFound, p)
+	}
+	return fmt.Errorf("while reading '%s': %w", p, err)
+}
+
+func syncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,126 @@
+package objstore_test
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/lsm-go/objstore"
+)
+
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := objstore.NewFileStore(dir)
+	require.NoError(t, err)
+
+	require.NoError(t, store.Put("wal/00000000000000000002.sst", []byte("data2")))
+	require.NoError(t, store.Put("wal/00000000000000000001.sst", []byte("data1")))
+	require.NoError(t, store.Put("compacted/00000000000000000001.sst", []byte("compacted")))
+	require.NoError(t, store.Put("manifest", []byte("manifest")))
+	require.NoError(t, store.Sync())
+
+	data, err := store.Get("wal/00000000000000000001.sst")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("data1"), data)
+
+	// Objects are stored as files relative to the root directory
+	data, err = os.ReadFile(filepath.Join(dir, "wal", "00000000000000000002.sst"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("data2"), data)
+
+	// Put replaces an existing object
+	require.NoError(t, store.Put("manifest", []byte("manifest-new")))
+	data, err = store.Get("manifest")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("manifest-new"), data)
+
+	for _, tt := range []struct {
+		prefix   string
+		expected []string
+	}{
+		{prefix: "wal/", expected: []string{"wal/00000000000000000001.sst", "wal/00000000000000000002.sst"}},
+		{prefix: "wal/00000000000000000002", expected: []string{"wal/00000000000000000002.sst"}},
+		{prefix: "man", expected: []string{"manifest"}},
+		{prefix: "missing/", expected: nil},
+		{prefix: "", expected: []string{
+			"compacted/00000000000000000001.sst",
+			"manifest",
+			"wal/00000000000000000001.sst",
+			"wal/00000000000000000002.sst",
+		}},
+	} {
+		paths, err := store.List(tt.prefix)
+		require.NoError(t, err)
+		assert.Equal(t, tt.expected, paths, "prefix '%s'", tt.prefix)
+	}
+
+	require.NoError(t, store.Delete("wal/00000000000000000001.sst"))
+	require.NoError(t, store.Delete("wal/00000000000000000001.sst"), "delete of a missing object is not an error")
+	require.NoError(t, store.Sync())
+	_, err = store.Get("wal/00000000000000000001.sst")
+	assert.ErrorIs(t, err, objstore.ErrNotFound)
+	paths, err := store.List("wal/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"wal/00000000000000000002.sst"}, paths)
+
+	// A new store sees the objects written by a previous store
+	reopened, err := objstore.NewFileStore(dir)
+	require.NoError(t, err)
+	paths, err = reopened.List("")
+	require.NoError(t, err)
+	assert.Len(t, paths, 3)
+}
+
+func TestFileStorePutIfNotExists(t *testing.T) {
+	store, err := objstore.NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	require.NoError(t, store.PutIfNotExists("wal/00000000000000000001.sst", []byte("first")))
+	err = store.PutIfNotExists("wal/00000000000000000001.sst", []byte("second"))
+	assert.ErrorIs(t, err, objstore.ErrAlreadyExists)
+	data, err := store.Get("wal/00000000000000000001.sst")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("first"), data)
+
+	// Exactly one of many concurrent writers succeeds
+	var wg sync.WaitGroup
+	errs := make([]error, 10)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = store.PutIfNotExists("wal/00000000000000000002.sst", []byte{byte(i)})
+		}()
+	}
+	wg.Wait()
+	var succeeded int
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		assert.ErrorIs(t, err, objstore.ErrAlreadyExists)
+	}
+	assert.Equal(t, 1, succeeded)
+
+	// No temporary files are left behind or listed
+	paths, err := store.List("")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"wal/00000000000000000001.sst", "wal/00000000000000000002.sst"}, paths)
+}
+
+func TestFileStoreInvalidPath(t *testing.T) {
+	store, err := objstore.NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	for _, p := range []string{"", "/abs", "../escape", "a/../b", "a//b", "./a", "a/", ".tmp/object"} {
+		assert.ErrorIs(t, store.Put(p, []byte("data")), objstore.ErrInvalidPath, "path '%s'", p)
+		_, err := store.Get(p)
+		assert.ErrorIs(t, err, objstore.ErrInvalidPath, "path '%s'", p)
+	}
+	_, err = store.List("../")
+	assert.ErrorIs(t, err, objstore.ErrInvalidPath)
+}
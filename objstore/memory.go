@@ -0,0 +1,308 @@
+package objstore
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInjected is returned by a MemoryStore operation which failed because of a Fault
+// without a Fault.Err
+var ErrInjected = errors.New("injected fault")
+
+// Op identifies the operations of a MemoryStore a Fault is injected into
+type Op int
+
+const (
+	// OpWrite is Put and PutIfNotExists
+	OpWrite Op = iota
+	// OpRead is Get, GetRange and Head
+	OpRead
+	OpList
+	OpDelete
+	OpSync
+)
+
+func (op Op) String() string {
+	switch op {
+	case OpWrite:
+		return "write"
+	case OpRead:
+		return "read"
+	case OpList:
+		return "list"
+	case OpDelete:
+		return "delete"
+	case OpSync:
+		return "sync"
+	}
+	return fmt.Sprintf("Op(%d)", int(op))
+}
+
+// Fault describes a failure injected into the operations of a MemoryStore. Faults are
+// applied in the order they were injected, the first fault which matches an operation
+// is applied to it.
+type Fault struct {
+	// Op is the operation the fault is injected into
+	Op Op
+
+	// Path if not empty limits the fault to objects with a path which begins with Path.
+	// For OpList the fault applies if the prefix listed begins with Path.
+	Path string
+
+	// Skip is the number of matching operations which succeed before the fault is injected
+	Skip int
+
+	// Count is the number of times the fault is injected before it is removed. If zero,
+	// the fault is injected into every matching operation until MemoryStore.ClearFaults.
+	Count int
+
+	// Latency is added to the operation before it is performed
+	Latency time.Duration
+
+	// Err if not nil is returned by the operation, which is not performed, unless
+	// PartialWrite is set.
+	Err error
+
+	// PartialWrite if greater than zero stores the first PartialWrite bytes of the object
+	// before the write fails with Err, or ErrInjected if Err is nil. Models a store which
+	// does not write objects atomically.
+	PartialWrite int
+
+	// TornTail if greater than zero drops the last TornTail bytes of the object, while
+	// reporting the write as successful. Models a write torn by a crash.
+	TornTail int
+
+	// BitFlips are the offsets of bits which are flipped in the data written by OpWrite,
+	// or in the data returned by OpRead. Offsets beyond the end of the data are ignored.
+	BitFlips []uint64
+}
+
+// MemoryStore is an object store which keeps all objects in memory. Faults can be injected
+// into each operation, such that tests can deterministically exercise the handling of
+// failures by code which uses an object store.
+type MemoryStore struct {
+	mu      sync.Mutex
+	objects map[string]memoryObject
+	faults  []*fault
+}
+
+var _ Bucket = (*MemoryStore)(nil)
+
+type memoryObject struct {
+	data     []byte
+	modified time.Time
+}
+
+type fault struct {
+	Fault
+	skipped  int
+	injected int
+}
+
+// NewMemoryStore returns a MemoryStore without any objects or faults
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{objects: make(map[string]memoryObject)}
+}
+
+// Inject adds a fault which is injected into matching operations
+func (s *MemoryStore) Inject(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &fault{Fault: f})
+}
+
+// ClearFaults removes all faults, such that every operation succeeds
+func (s *MemoryStore) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// Put writes data as an object at the provided path, replacing any existing object
+func (s *MemoryStore) Put(p string, data []byte) error {
+	return s.put(p, data, false)
+}
+
+// PutIfNotExists writes data as an object at the provided path only if no object
+// exists at the path, returns ErrAlreadyExists if an object exists at the path.
+func (s *MemoryStore) PutIfNotExists(p string, data []byte) error {
+	return s.put(p, data, true)
+}
+
+func (s *MemoryStore) put(p string, data []byte, ifNotExists bool) error {
+	if err := validatePath(p); err != nil {
+		return fmt.Errorf("%w: '%s'", err, p)
+	}
+	f := s.inject(OpWrite, p)
+	if f != nil && f.Err != nil && f.PartialWrite <= 0 {
+		return f.Err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.objects[p]; ok && ifNotExists {
+		return fmt.Errorf("%w: '%s'", ErrAlreadyExists, p)
+	}
+	if f == nil {
+		s.objects[p] = memoryObject{data: append([]byte(nil), data...), modified: time.Now()}
+		return nil
+	}
+
+	data = flipBits(data, f.BitFlips)
+	if f.PartialWrite > 0 {
+		s.objects[p] = memoryObject{data: data[:min(f.PartialWrite, len(data))], modified: time.Now()}
+		return injectedErr(f.Err)
+	}
+	if f.TornTail > 0 {
+		data = data[:max(len(data)-f.TornTail, 0)]
+	}
+	s.objects[p] = memoryObject{data: data, modified: time.Now()}
+	return nil
+}
+
+// Get returns the contents of the object at the provided path
+func (s *MemoryStore) Get(p string) ([]byte, error) {
+	obj, err := s.read(p)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), obj.data...), nil
+}
+
+// GetRange returns the bytes of the object within the range, returns ErrInvalidRange
+// if the range is not within the bounds of the object.
+func (s *MemoryStore) GetRange(p string, r Range) ([]byte, error) {
+	obj, err := s.read(p)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkRange(p, r, uint64(len(obj.data))); err != nil {
+		return nil, err
+	}
+	return append([]byte{}, obj.data[r.Start:r.End]...), nil
+}
+
+// Head returns the size and the time the object was last written
+func (s *MemoryStore) Head(p string) (ObjectInfo, error) {
+	obj, err := s.read(p)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return ObjectInfo{Size: uint64(len(obj.data)), LastModified: obj.modified}, nil
+}
+
+// read returns the object after applying any fault, the data returned must not be modified
+func (s *MemoryStore) read(p string) (memoryObject, error) {
+	if err := validatePath(p); err != nil {
+		return memoryObject{}, fmt.Errorf("%w: '%s'", err, p)
+	}
+	f := s.inject(OpRead, p)
+	if f != nil && f.Err != nil {
+		return memoryObject{}, f.Err
+	}
+
+	s.mu.Lock()
+	obj, ok := s.objects[p]
+	s.mu.Unlock()
+	if !ok {
+		return memoryObject{}, fmt.Errorf("%w: '%s'", ErrNotFound, p)
+	}
+	if f != nil {
+		obj.data = flipBits(obj.data, f.BitFlips)
+	}
+	return obj, nil
+}
+
+// List returns the paths of all the objects which begin with the provided prefix in
+// lexicographical order
+func (s *MemoryStore) List(prefix string) ([]string, error) {
+	if f := s.inject(OpList, prefix); f != nil && f.Err != nil {
+		return nil, f.Err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var paths []string
+	for p := range s.objects {
+		if strings.HasPrefix(p, prefix) {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Delete removes the object at the provided path. Deleting an object which does not
+// exist is not an error.
+func (s *MemoryStore) Delete(p string) error {
+	if err := validatePath(p); err != nil {
+		return fmt.Errorf("%w: '%s'", err, p)
+	}
+	if f := s.inject(OpDelete, p); f != nil && f.Err != nil {
+		return f.Err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, p)
+	return nil
+}
+
+// Sync returns the error of any OpSync fault, objects in memory are always durable
+func (s *MemoryStore) Sync() error {
+	if f := s.inject(OpSync, ""); f != nil && f.Err != nil {
+		return f.Err
+	}
+	return nil
+}
+
+// inject returns the first fault which matches the operation after sleeping for the
+// latency of the fault, returns nil if no fault matches.
+func (s *MemoryStore) inject(op Op, p string) *Fault {
+	s.mu.Lock()
+	var match *Fault
+	for i, f := range s.faults {
+		if f.Op != op || !strings.HasPrefix(p, f.Path) {
+			continue
+		}
+		if f.skipped < f.Skip {
+			f.skipped++
+			continue
+		}
+
+		f.injected++
+		if f.Count != 0 && f.injected >= f.Count {
+			s.faults = append(s.faults[:i:i], s.faults[i+1:]...)
+		}
+		match = &f.Fault
+		break
+	}
+	s.mu.Unlock()
+
+	if match != nil && match.Latency > 0 {
+		time.Sleep(match.Latency)
+	}
+	return match
+}
+
+// flipBits returns a copy of data with the bits at the provided offsets flipped
+func flipBits(data []byte, offsets []uint64) []byte {
+	data = append([]byte(nil), data...)
+	for _, off := range offsets {
+		if off/8 < uint64(len(data)) {
+			data[off/8] ^= 1 << (off % 8)
+		}
+	}
+	return data
+}
+
+func injectedErr(err error) error {
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: partial write", ErrInjected)
+}
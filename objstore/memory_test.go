@@ -0,0 +1,163 @@
+package objstore_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/lsm-go/internal/sstable"
+	"github.com/thrawn01/lsm-go/objstore"
+)
+
+func TestMemoryStore(t *testing.T) {
+	store := objstore.NewMemoryStore()
+
+	data := []byte("data1")
+	require.NoError(t, store.Put("wal/00000000000000000002.sst", []byte("data2")))
+	require.NoError(t, store.Put("wal/00000000000000000001.sst", data))
+	require.NoError(t, store.Put("manifest", []byte("manifest")))
+	require.NoError(t, store.Sync())
+
+	// The store keeps a copy of the data written
+	data[0] = 'X'
+	read, err := store.Get("wal/00000000000000000001.sst")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("data1"), read)
+
+	paths, err := store.List("wal/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"wal/00000000000000000001.sst", "wal/00000000000000000002.sst"}, paths)
+
+	err = store.PutIfNotExists("manifest", []byte("manifest-new"))
+	assert.ErrorIs(t, err, objstore.ErrAlreadyExists)
+
+	require.NoError(t, store.Delete("wal/00000000000000000001.sst"))
+	_, err = store.Get("wal/00000000000000000001.sst")
+	assert.ErrorIs(t, err, objstore.ErrNotFound)
+
+	blob := objstore.NewBlob(store, "wal/00000000000000000002.sst")
+	size, err := blob.Len()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), size)
+	read, err = blob.ReadRange(sstable.Range{Start: 1, End: 4})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("ata"), read)
+	_, err = blob.ReadRange(sstable.Range{Start: 1, End: 6})
+	assert.ErrorIs(t, err, objstore.ErrInvalidRange)
+}
+
+func TestMemoryStoreFaults(t *testing.T) {
+	errNetwork := errors.New("network error")
+	data := []byte{0x00, 0x00, 0x00, 0x00}
+
+	for _, tt := range []struct {
+		name  string
+		fault objstore.Fault
+		// writeErr is the error expected from the write, nil if the write succeeds
+		writeErr error
+		// expected is the object stored, nil if no object is stored
+		expected []byte
+	}{
+		{
+			name:     "Error",
+			fault:    objstore.Fault{Op: objstore.OpWrite, Err: errNetwork},
+			writeErr: errNetwork,
+		},
+		{
+			name:     "PartialWrite",
+			fault:    objstore.Fault{Op: objstore.OpWrite, PartialWrite: 3},
+			writeErr: objstore.ErrInjected,
+			expected: []byte{0x00, 0x00, 0x00},
+		},
+		{
+			name:     "PartialWriteWithErr",
+			fault:    objstore.Fault{Op: objstore.OpWrite, PartialWrite: 1, Err: errNetwork},
+			writeErr: errNetwork,
+			expected: []byte{0x00},
+		},
+		{
+			name:     "TornTail",
+			fault:    objstore.Fault{Op: objstore.OpWrite, TornTail: 3},
+			expected: []byte{0x00},
+		},
+		{
+			name:     "BitFlips",
+			fault:    objstore.Fault{Op: objstore.OpWrite, BitFlips: []uint64{0, 9, 31, 32}},
+			expected: []byte{0x01, 0x02, 0x00, 0x80},
+		},
+		{
+			name:     "OtherPath",
+			fault:    objstore.Fault{Op: objstore.OpWrite, Path: "other/", Err: errNetwork},
+			expected: data,
+		},
+		{
+			name:     "OtherOp",
+			fault:    objstore.Fault{Op: objstore.OpRead, Err: errNetwork},
+			expected: data,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			store := objstore.NewMemoryStore()
+			store.Inject(tt.fault)
+
+			err := store.Put("wal/1.sst", data)
+			if tt.writeErr != nil {
+				assert.ErrorIs(t, err, tt.writeErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			store.ClearFaults()
+			read, err := store.Get("wal/1.sst")
+			if tt.expected == nil {
+				assert.ErrorIs(t, err, objstore.ErrNotFound)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, read)
+		})
+	}
+}
+
+func TestMemoryStoreFaultCounts(t *testing.T) {
+	errNetwork := errors.New("network error")
+	store := objstore.NewMemoryStore()
+	require.NoError(t, store.Put("wal/1.sst", []byte("data")))
+
+	// The first read succeeds, the next two fail, then the fault is removed
+	store.Inject(objstore.Fault{Op: objstore.OpRead, Path: "wal/", Skip: 1, Count: 2, Err: errNetwork})
+	var errs []error
+	for i := 0; i < 4; i++ {
+		_, err := store.Get("wal/1.sst")
+		errs = append(errs, err)
+	}
+	assert.Equal(t, []error{nil, errNetwork, errNetwork, nil}, errs)
+
+	// Bits are flipped in the data read, not in the stored object
+	store.Inject(objstore.Fault{Op: objstore.OpRead, Count: 1, BitFlips: []uint64{5}})
+	read, err := objstore.NewBlob(store, "wal/1.sst").ReadRange(sstable.Range{Start: 0, End: 4})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("Data"), read)
+	read, err = store.Get("wal/1.sst")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("data"), read)
+
+	for _, op := range []objstore.Op{objstore.OpList, objstore.OpDelete, objstore.OpSync} {
+		store.Inject(objstore.Fault{Op: op, Count: 1, Err: errNetwork})
+	}
+	_, err = store.List("wal/")
+	assert.ErrorIs(t, err, errNetwork)
+	assert.ErrorIs(t, store.Delete("wal/1.sst"), errNetwork)
+	assert.ErrorIs(t, store.Sync(), errNetwork)
+	_, err = store.Get("wal/1.sst")
+	assert.NoError(t, err, "object is not deleted when delete fails")
+
+	// Latency delays the operation
+	store.Inject(objstore.Fault{Op: objstore.OpList, Count: 1, Latency: 50 * time.Millisecond})
+	start := time.Now()
+	_, err = store.List("wal/")
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+}
@@ -0,0 +1,131 @@
+package objstore
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/thrawn01/lsm-go/internal/sstable"
+)
+
+var (
+	// ErrNotFound is returned when no object exists at the path
+	ErrNotFound = errors.New("object not found")
+
+	// ErrAlreadyExists is returned by PutIfNotExists when an object already exists at the path
+	ErrAlreadyExists = errors.New("object already exists")
+
+	// ErrInvalidPath is returned when the path of an object is not a relative, slash separated
+	// path without any '.' or '..' elements
+	ErrInvalidPath = errors.New("invalid object path")
+
+	// ErrInvalidRange is returned when a ranged read is outside the bounds of the object
+	ErrInvalidRange = errors.New("invalid range")
+)
+
+// Bucket is a path addressable store of objects, such as an S3 bucket or a directory on the
+// local filesystem. Every component which reads or writes objects, such as the WAL, the
+// SSTable reader, the manifest store and garbage collection, uses a Bucket.
+type Bucket interface {
+	// Put writes data as an object at the provided path, replacing any existing object
+	Put(path string, data []byte) error
+
+	// PutIfNotExists writes data as an object at the provided path only if no object
+	// exists at the path, returns ErrAlreadyExists if an object exists at the path.
+	PutIfNotExists(path string, data []byte) error
+
+	// Get returns the contents of the object at the provided path, returns ErrNotFound
+	// if no object exists at the path.
+	Get(path string) ([]byte, error)
+
+	// GetRange returns the bytes of the object at the provided path within the range,
+	// returns ErrInvalidRange if the range is not within the bounds of the object.
+	GetRange(path string, r Range) ([]byte, error)
+
+	// Head returns the attributes of the object at the provided path without reading
+	// the object, returns ErrNotFound if no object exists at the path.
+	Head(path string) (ObjectInfo, error)
+
+	// List returns the paths of all the objects which begin with the provided prefix
+	// in lexicographical order
+	List(prefix string) ([]string, error)
+
+	// Delete removes the object at the provided path. Deleting an object which does not
+	// exist is not an error.
+	Delete(path string) error
+
+	// Sync ensures all previously written and deleted objects are durable
+	Sync() error
+}
+
+// Range is the range of bytes [Start, End) of an object read by Bucket.GetRange()
+type Range struct {
+	Start uint64
+	End   uint64
+}
+
+// ObjectInfo are the attributes of an object returned by Bucket.Head()
+type ObjectInfo struct {
+	// Size is the size of the object in bytes
+	Size uint64
+
+	// LastModified is the time the object was last written
+	LastModified time.Time
+}
+
+// Blob is a sstable.ReadOnlyBlob of the object at a path in a Bucket, which reads
+// ranges of the object without reading the entire object.
+type Blob struct {
+	bucket Bucket
+	path   string
+}
+
+var _ sstable.ReadOnlyBlob = (*Blob)(nil)
+
+// NewBlob returns a Blob of the object at the provided path in the bucket
+func NewBlob(bucket Bucket, path string) *Blob {
+	return &Blob{bucket: bucket, path: path}
+}
+
+// Len returns the size of the object in bytes
+func (b *Blob) Len() (uint64, error) {
+	info, err := b.bucket.Head(b.path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size, nil
+}
+
+// ReadRange returns the bytes of the object within the range
+func (b *Blob) ReadRange(r sstable.Range) ([]byte, error) {
+	return b.bucket.GetRange(b.path, Range{Start: r.Start, End: r.End})
+}
+
+// Read returns the entire contents of the object
+func (b *Blob) Read() ([]byte, error) {
+	return b.bucket.Get(b.path)
+}
+
+// Id returns the path of the object
+func (b *Blob) Id() string {
+	return b.path
+}
+
+// validatePath returns ErrInvalidPath if p is not a clean, relative, slash separated path
+func validatePath(p string) error {
+	if p == "" || strings.HasPrefix(p, "/") || path.Clean(p) != p ||
+		p == ".." || strings.HasPrefix(p, "../") {
+		return ErrInvalidPath
+	}
+	return nil
+}
+
+// checkRange returns ErrInvalidRange if the range is not within an object of the provided size
+func checkRange(p string, r Range, size uint64) error {
+	if r.Start > r.End || r.End > size {
+		return fmt.Errorf("%w: [%d, %d) of '%s' with length %d", ErrInvalidRange, r.Start, r.End, p, size)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package objstore_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/lsm-go/internal/sstable"
+	"github.com/thrawn01/lsm-go/objstore"
+)
+
+func TestBucket(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		newBucket func(t *testing.T) objstore.Bucket
+	}{
+		{
+			name: "FileStore",
+			newBucket: func(t *testing.T) objstore.Bucket {
+				store, err := objstore.NewFileStore(t.TempDir())
+				require.NoError(t, err)
+				return store
+			},
+		},
+		{
+			name: "MemoryStore",
+			newBucket: func(t *testing.T) objstore.Bucket {
+				return objstore.NewMemoryStore()
+			},
+		},
+		{
+			name: "S3Store",
+			newBucket: func(t *testing.T) objstore.Bucket {
+				store, _ := newS3Store(t, false)
+				return store
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			bucket := tt.newBucket(t)
+			before := time.Now().Add(-time.Minute)
+			require.NoError(t, bucket.Put("sst/1.sst", []byte("0123456789")))
+			require.NoError(t, bucket.PutIfNotExists("sst/2.sst", []byte("data")))
+			assert.ErrorIs(t, bucket.PutIfNotExists("sst/2.sst", []byte("new")), objstore.ErrAlreadyExists)
+
+			data, err := bucket.Get("sst/2.sst")
+			require.NoError(t, err)
+			assert.Equal(t, []byte("data"), data)
+
+			info, err := bucket.Head("sst/1.sst")
+			require.NoError(t, err)
+			assert.Equal(t, uint64(10), info.Size)
+			assert.True(t, info.LastModified.After(before), "LastModified %s", info.LastModified)
+
+			for _, r := range []struct {
+				r        objstore.Range
+				expected []byte
+			}{
+				{r: objstore.Range{Start: 2, End: 5}, expected: []byte("234")},
+				{r: objstore.Range{Start: 7, End: 10}, expected: []byte("789")},
+				{r: objstore.Range{Start: 10, End: 10}, expected: []byte{}},
+			} {
+				data, err := bucket.GetRange("sst/1.sst", r.r)
+				require.NoError(t, err)
+				assert.Equal(t, r.expected, data)
+			}
+			for _, r := range []objstore.Range{{Start: 5, End: 11}, {Start: 10, End: 12}, {Start: 5, End: 4}} {
+				_, err = bucket.GetRange("sst/1.sst", r)
+				assert.ErrorIs(t, err, objstore.ErrInvalidRange, "range [%d, %d)", r.Start, r.End)
+			}
+
+			// A Blob reads the object through the bucket
+			blob := objstore.NewBlob(bucket, "sst/1.sst")
+			assert.Equal(t, "sst/1.sst", blob.Id())
+			size, err := blob.Len()
+			require.NoError(t, err)
+			assert.Equal(t, uint64(10), size)
+			data, err = blob.ReadRange(sstable.Range{Start: 2, End: 5})
+			require.NoError(t, err)
+			assert.Equal(t, []byte("234"), data)
+			data, err = blob.Read()
+			require.NoError(t, err)
+			assert.Equal(t, []byte("0123456789"), data)
+
+			paths, err := bucket.List("sst/")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"sst/1.sst", "sst/2.sst"}, paths)
+
+			require.NoError(t, bucket.Delete("sst/1.sst"))
+			require.NoError(t, bucket.Delete("sst/1.sst"), "delete of a missing object is not an error")
+			require.NoError(t, bucket.Sync())
+
+			_, err = bucket.Get("sst/1.sst")
+			assert.ErrorIs(t, err, objstore.ErrNotFound)
+			_, err = bucket.GetRange("sst/1.sst", objstore.Range{Start: 0, End: 1})
+			assert.ErrorIs(t, err, objstore.ErrNotFound)
+			_, err = bucket.Head("sst/1.sst")
+			assert.ErrorIs(t, err, objstore.ErrNotFound)
+			_, err = objstore.NewBlob(bucket, "sst/1.sst").Len()
+			assert.ErrorIs(t, err, objstore.ErrNotFound)
+
+			// A directory of objects is not itself an object
+			_, err = bucket.Head("sst")
+			assert.ErrorIs(t, err, objstore.ErrNotFound)
+		})
+	}
+}
@@ -0,0 +1,326 @@
+package objstore
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type S3Config struct {
+	// Endpoint is the URL of the S3 API, such as `https://s3.us-east-1.amazonaws.com`
+	// or `http://localhost:9000` for MinIO
+	Endpoint string
+
+	// Bucket is the name of the bucket objects are stored in
+	Bucket string
+
+	// Region is the region of the bucket used to sign requests. Defaults to `us-east-1`
+	Region string
+
+	// Credentials are used to sign each request
+	Credentials Credentials
+
+	// VirtualHostedStyle if true addresses the bucket as a subdomain of the endpoint,
+	// such as `https://<bucket>.s3.us-east-1.amazonaws.com/<path>`. Otherwise the bucket
+	// is addressed as the first element of the path, such as `http://localhost:9000/<bucket>/<path>`
+	VirtualHostedStyle bool
+
+	// HTTPClient is the client used to make requests. Defaults to a client with a 30s timeout
+	HTTPClient *http.Client
+
+	// Now returns the time used to sign requests. Defaults to time.Now
+	Now func() time.Time
+}
+
+// S3Store is an object store which stores each object in a bucket of an S3 compatible API.
+// A successful PUT is durable, so Sync does nothing. PutIfNotExists uses a conditional
+// PUT with `If-None-Match: *`, which requires a store which supports conditional writes.
+type S3Store struct {
+	conf     S3Config
+	endpoint *url.URL
+}
+
+var _ Bucket = (*S3Store)(nil)
+
+// S3Error is an error response returned by the S3 API
+type S3Error struct {
+	StatusCode int
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *S3Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("S3 request failed with status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("S3 request failed with status %d: %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// NewS3Store returns an S3Store which stores objects in S3Config.Bucket
+func NewS3Store(conf S3Config) (*S3Store, error) {
+	if conf.Bucket == "" {
+		return nil, errors.New("S3Config.Bucket cannot be empty")
+	}
+	endpoint, err := url.Parse(conf.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("while parsing S3Config.Endpoint: %w", err)
+	}
+	if endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("S3Config.Endpoint '%s' must be an absolute URL", conf.Endpoint)
+	}
+	if conf.Region == "" {
+		conf.Region = "us-east-1"
+	}
+	if conf.HTTPClient == nil {
+		conf.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	if conf.Now == nil {
+		conf.Now = time.Now
+	}
+	return &S3Store{conf: conf, endpoint: endpoint}, nil
+}
+
+// Put writes data as an object at the provided path, replacing any existing object
+func (s *S3Store) Put(p string, data []byte) error {
+	if err := validatePath(p); err != nil {
+		return fmt.Errorf("%w: '%s'", err, p)
+	}
+	resp, err := s.do(http.MethodPut, p, nil, nil, data)
+	if err != nil {
+		return fmt.Errorf("while writing '%s': %w", p, err)
+	}
+	return resp.Body.Close()
+}
+
+// PutIfNotExists writes data as an object at the provided path only if no object
+// exists at the path, returns ErrAlreadyExists if an object exists at the path.
+func (s *S3Store) PutIfNotExists(p string, data []byte) error {
+	if err := validatePath(p); err != nil {
+		return fmt.Errorf("%w: '%s'", err, p)
+	}
+	resp, err := s.do(http.MethodPut, p, nil, http.Header{"If-None-Match": {"*"}}, data)
+	if err != nil {
+		var s3Err *S3Error
+		if errors.As(err, &s3Err) && s3Err.StatusCode == http.StatusPreconditionFailed {
+			return fmt.Errorf("%w: '%s'", ErrAlreadyExists, p)
+		}
+		return fmt.Errorf("while writing '%s': %w", p, err)
+	}
+	return resp.Body.Close()
+}
+
+// Get returns the contents of the object at the provided path
+func (s *S3Store) Get(p string) ([]byte, error) {
+	if err := validatePath(p); err != nil {
+		return nil, fmt.Errorf("%w: '%s'", err, p)
+	}
+	return s.get(p, nil)
+}
+
+// GetRange returns the bytes of the object within the range using a GET request with a
+// Range header, returns ErrInvalidRange if the range is not within the bounds of the object.
+func (s *S3Store) GetRange(p string, r Range) ([]byte, error) {
+	if err := validatePath(p); err != nil {
+		return nil, fmt.Errorf("%w: '%s'", err, p)
+	}
+	if r.Start > r.End {
+		return nil, fmt.Errorf("%w: [%d, %d) of '%s'", ErrInvalidRange, r.Start, r.End, p)
+	}
+	// An empty range cannot be expressed as an HTTP range
+	if r.Start == r.End {
+		return []byte{}, nil
+	}
+
+	data, err := s.get(p, http.Header{"Range": {fmt.Sprintf("bytes=%d-%d", r.Start, r.End-1)}})
+	if err != nil {
+		var s3Err *S3Error
+		if errors.As(err, &s3Err) && s3Err.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+			return nil, fmt.Errorf("%w: [%d, %d) of '%s'", ErrInvalidRange, r.Start, r.End, p)
+		}
+		return nil, err
+	}
+	// The server returns fewer bytes if the range extends beyond the end of the object
+	if uint64(len(data)) != r.End-r.Start {
+		return nil, fmt.Errorf("%w: [%d, %d) of '%s' returned %d bytes",
+			ErrInvalidRange, r.Start, r.End, p, len(data))
+	}
+	return data, nil
+}
+
+// Head returns the size and modification time of the object using a HEAD request
+func (s *S3Store) Head(p string) (ObjectInfo, error) {
+	if err := validatePath(p); err != nil {
+		return ObjectInfo{}, fmt.Errorf("%w: '%s'", err, p)
+	}
+	resp, err := s.do(http.MethodHead, p, nil, nil, nil)
+	if err != nil {
+		return ObjectInfo{}, s.wrapErr(p, err)
+	}
+	_ = resp.Body.Close()
+
+	size, err := strconv.ParseUint(resp.Header.Get("Content-Length"), 10, 64)
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("while reading length of '%s': invalid Content-Length: %w", p, err)
+	}
+	info := ObjectInfo{Size: size}
+	// Last-Modified is optional, an object without one has a zero LastModified
+	if lm := resp.Header.Get("Last-Modified"); lm != "" {
+		if info.LastModified, err = http.ParseTime(lm); err != nil {
+			return ObjectInfo{}, fmt.Errorf("while reading '%s': invalid Last-Modified: %w", p, err)
+		}
+	}
+	return info, nil
+}
+
+// List returns the paths of all the objects which begin with the provided prefix in
+// lexicographical order
+func (s *S3Store) List(prefix string) ([]string, error) {
+	var paths []string
+	var token string
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := s.do(http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("while listing '%s': %w", prefix, err)
+		}
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("while decoding list of '%s': %w", prefix, err)
+		}
+
+		for _, c := range result.Contents {
+			paths = append(paths, c.Key)
+		}
+		if !result.IsTruncated {
+			return paths, nil
+		}
+		if result.NextContinuationToken == "" {
+			return nil, fmt.Errorf("while listing '%s': truncated list without a continuation token", prefix)
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// Delete removes the object at the provided path. Deleting an object which does not
+// exist is not an error.
+func (s *S3Store) Delete(p string) error {
+	if err := validatePath(p); err != nil {
+		return fmt.Errorf("%w: '%s'", err, p)
+	}
+	resp, err := s.do(http.MethodDelete, p, nil, nil, nil)
+	if err != nil {
+		var s3Err *S3Error
+		if errors.As(err, &s3Err) && s3Err.StatusCode == http.StatusNotFound {
+			return nil
+		}
+		return fmt.Errorf("while deleting '%s': %w", p, err)
+	}
+	return resp.Body.Close()
+}
+
+// Sync does nothing, as objects are durable once a PUT succeeds
+func (s *S3Store) Sync() error {
+	return nil
+}
+
+// get returns the body of a GET of the object with the provided headers
+func (s *S3Store) get(p string, header http.Header) ([]byte, error) {
+	resp, err := s.do(http.MethodGet, p, nil, header, nil)
+	if err != nil {
+		return nil, s.wrapErr(p, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("while reading '%s': %w", p, err)
+	}
+	return data, nil
+}
+
+// wrapErr returns ErrNotFound if err is a response indicating the object does not exist
+func (s *S3Store) wrapErr(p string, err error) error {
+	var s3Err *S3Error
+	if errors.As(err, &s3Err) && s3Err.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: '%s'", ErrNotFound, p)
+	}
+	return fmt.Errorf("while reading '%s': %w", p, err)
+}
+
+// do sends a signed request for the object at the provided path, or for the bucket if
+// the path is empty. Returns an *S3Error if the response status is not 2xx.
+func (s *S3Store) do(method, p string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	u := *s.endpoint
+	u.RawQuery = query.Encode()
+	objectPath := p
+	if s.conf.VirtualHostedStyle {
+		u.Host = s.conf.Bucket + "." + u.Host
+	} else {
+		objectPath = s.conf.Bucket + "/" + p
+	}
+	// Encode the path exactly as it is encoded when signed
+	basePath := strings.TrimSuffix(u.Path, "/")
+	u.Path = basePath + "/" + objectPath
+	u.RawPath = strings.TrimSuffix(s.endpoint.EscapedPath(), "/") + "/" + encodePath(objectPath)
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.ContentLength = int64(len(body))
+
+	payloadHash := emptyPayloadHash
+	if len(body) != 0 {
+		payloadHash = hashHex(body)
+	}
+	signV4(req, payloadHash, s.conf.Credentials, s.conf.Region, s.conf.Now())
+
+	resp, err := s.conf.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	s3Err := &S3Error{StatusCode: resp.StatusCode}
+	// HEAD responses and some errors have no body
+	if data, err := io.ReadAll(resp.Body); err == nil && len(data) != 0 {
+		_ = xml.Unmarshal(data, s3Err)
+	}
+	return nil, s3Err
+}
+
+// encodePath URI encodes each element of the path
+func encodePath(p string) string {
+	elements := strings.Split(p, "/")
+	for i, e := range elements {
+		elements[i] = uriEncode(e)
+	}
+	return strings.Join(elements, "/")
+}
+
+type listBucketResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+}
@@ -0,0 +1,76 @@
+package wal
+
+import (
+	"context"
+)
+
+// WriteBatch is a group of puts and deletes which are applied to the WAL atomically
+// using WAL.Write(). Either all or none of the writes in the batch are visible to
+// WAL.Get(), and all the writes in the batch are flushed in the same WAL SSTable.
+//
+// If the same key is written more than once, the last write in the batch wins.
+type WriteBatch struct {
+	entries []batchEntry
+}
+
+type batchEntry struct {
+	key   []byte
+	value ValueDeletable
+}
+
+// Put adds a put of the key and value to the batch. The key and value are
+// copied, such that the caller may reuse them after Put returns.
+func (b *WriteBatch) Put(k []byte, v []byte) {
+	b.entries = append(b.entries, batchEntry{
+		key:   append([]byte(nil), k...),
+		value: ValueDeletable{Value: append([]byte(nil), v...)},
+	})
+}
+
+// Merge adds a merge operand for the key to the batch, see WAL.Merge(). The key and
+// operand are copied, such that the caller may reuse them after Merge returns.
+func (b *WriteBatch) Merge(k []byte, operand []byte) {
+	b.entries = append(b.entries, batchEntry{
+		key:   append([]byte(nil), k...),
+		value: ValueDeletable{Value: append([]byte(nil), operand...), IsMerge: true},
+	})
+}
+
+// Delete adds a delete of the key to the batch. The key is copied, such
+// that the caller may reuse it after Delete returns.
+func (b *WriteBatch) Delete(k []byte) {
+	b.entries = append(b.entries, batchEntry{
+		key:   append([]byte(nil), k...),
+		value: ValueDeletable{IsDelete: true},
+	})
+}
+
+// Len returns the number of writes in the batch
+func (b *WriteBatch) Len() int {
+	return len(b.entries)
+}
+
+// Reset removes all writes from the batch, such that it can be reused
+func (b *WriteBatch) Reset() {
+	b.entries = b.entries[:0]
+}
+
+// Write applies all the writes in the batch to the WAL atomically. If any write in the batch
+// is invalid, none of the writes are applied and the error is returned. If Options.AwaitFlush
+// is true, Write blocks until the table containing the batch has been flushed to the
+// object store. See WAL.write() for the conditions under which Write stalls.
+func (w *WAL) Write(ctx context.Context, b *WriteBatch, opts Options) error {
+	if b.Len() == 0 {
+		return nil
+	}
+
+	table, err := w.write(ctx, opts.expireAt(), b.entries...)
+	if err != nil {
+		return err
+	}
+
+	if opts.AwaitFlush {
+		return table.awaitDurable(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package wal
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/lsm-go/internal/sstable/types"
+)
+
+func TestWriteBatch(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	w, err := NewWAL(Config{Store: store, FlushInterval: time.Hour, SSTable: testSSTableConfig, OnFlush: discard})
+	require.NoError(t, err)
+	defer func() { _ = w.Close(context.Background()) }()
+
+	key := []byte("key1")
+	var b WriteBatch
+	b.Put(key, []byte("value1"))
+	b.Put([]byte("key2"), []byte("value2"))
+	b.Delete([]byte("key3"))
+	b.Put([]byte("key4"), []byte("value4"))
+	b.Delete([]byte("key4"))
+	assert.Equal(t, 5, b.Len())
+
+	// The batch must not be affected by changes to the caller's slices
+	key[0] = 'X'
+
+	done := make(chan error)
+	go func() {
+		done <- w.Write(ctx, &b, Options{AwaitFlush: true})
+	}()
+
+	require.Eventually(t, func() bool {
+		w.mu.RLock()
+		defer w.mu.RUnlock()
+		return w.activeTable.skl.Len() == 4
+	}, 5*time.Second, time.Millisecond)
+
+	v, err := w.Get([]byte("key1"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value1"), v)
+	v, err = w.Get([]byte("key2"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value2"), v)
+	_, err = w.Get([]byte("key3"))
+	assert.Error(t, err)
+	_, err = w.Get([]byte("key4"))
+	assert.Error(t, err)
+
+	w.flushActiveTable()
+	require.NoError(t, <-done)
+
+	// The entire batch was flushed to the same WAL SST
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	require.Equal(t, []string{walPath(1), walPath(2)}, store.names)
+	assert.Equal(t, []types.KeyValue{
+		{Key: []byte("key1"), Value: types.Value{Value: []byte("value1")}, Seq: 1},
+		{Key: []byte("key2"), Value: types.Value{Value: []byte("value2")}, Seq: 1},
+		{Key: []byte("key3"), Value: types.Value{IsTombstone: true}, Seq: 1},
+		{Key: []byte("key4"), Value: types.Value{IsTombstone: true}, Seq: 1},
+	}, readEntries(t, testSSTableConfig, store.objects[walPath(2)]))
+}
+
+func TestWriteBatchEmpty(t *testing.T) {
+	ctx := context.Background()
+	w, err := NewWAL(Config{Store: newMockStore(), FlushInterval: time.Hour, SSTable: testSSTableConfig, OnFlush: discard})
+	require.NoError(t, err)
+	defer func() { _ = w.Close(context.Background()) }()
+
+	var b WriteBatch
+	require.NoError(t, w.Write(ctx, &b, Options{AwaitFlush: true}))
+
+	b.Put([]byte("key1"), []byte("value1"))
+	b.Reset()
+	assert.Equal(t, 0, b.Len())
+	require.NoError(t, w.Write(ctx, &b, Options{}))
+	assert.Equal(t, 0, w.activeTable.skl.Len())
+}
+
+func TestWriteBatchAtomicVisibility(t *testing.T) {
+	ctx := context.Background()
+	w, err := NewWAL(Config{Store: newMockStore(), FlushInterval: time.Millisecond, SSTable: testSSTableConfig, OnFlush: discard})
+	require.NoError(t, err)
+	defer func() { _ = w.Close(context.Background()) }()
+
+	const keys = 10
+	const batches = 100
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+
+			// Every key must have the value written by the same batch
+			w.mu.RLock()
+			first, err := w.get([]byte("key-0"))
+			for i := 1; i < keys && err == nil; i++ {
+				v, err := w.get([]byte(fmt.Sprintf("key-%d", i)))
+				if !assert.NoError(t, err) || !assert.Equal(t, first, v) {
+					w.mu.RUnlock()
+					return
+				}
+			}
+			w.mu.RUnlock()
+		}
+	}()
+
+	var b WriteBatch
+	for n := 0; n < batches; n++ {
+		b.Reset()
+		for i := 0; i < keys; i++ {
+			b.Put([]byte(fmt.Sprintf("key-%d", i)), []byte(fmt.Sprintf("batch-%d", n)))
+		}
+		require.NoError(t, w.Write(ctx, &b, Options{}))
+	}
+	close(stop)
+	wg.Wait()
+}
@@ -0,0 +1,162 @@
+package wal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloseDrainsActiveTable(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	var tables tableCollector
+	w, err := NewWAL(Config{
+		Store:         store,
+		FlushInterval: time.Hour,
+		SSTable:       testSSTableConfig,
+		OnFlush:       tables.OnFlush,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, w.Put(ctx, []byte("key1"), []byte("value1"), Options{}))
+	require.NoError(t, w.Put(ctx, []byte("key2"), []byte("value2"), Options{}))
+	require.NoError(t, w.Close(ctx))
+
+	// The unflushed writes are durable once Close returns
+	assert.Equal(t, uint64(2), w.LastId())
+	assert.Equal(t, []uint64{2}, tables.Ids())
+	store.mu.Lock()
+	assert.Equal(t, 2, len(readEntries(t, testSSTableConfig, store.objects[walPath(2)])))
+	store.mu.Unlock()
+
+	// All operations are rejected once closed
+	assert.ErrorIs(t, w.Put(ctx, []byte("key3"), []byte("value3"), Options{}), ErrClosed)
+	assert.ErrorIs(t, w.Delete(ctx, []byte("key1")), ErrClosed)
+	var b WriteBatch
+	b.Put([]byte("key3"), []byte("value3"))
+	assert.ErrorIs(t, w.Write(ctx, &b, Options{}), ErrClosed)
+	_, err = w.Get([]byte("key1"))
+	assert.ErrorIs(t, err, ErrClosed)
+	_, err = w.Scan(nil, nil)
+	assert.ErrorIs(t, err, ErrClosed)
+	assert.ErrorIs(t, w.Close(ctx), ErrClosed)
+}
+
+func TestCloseWaitsForOutstandingFlushes(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	w, err := NewWAL(Config{Store: store, FlushInterval: time.Hour, SSTable: testSSTableConfig, OnFlush: discard})
+	require.NoError(t, err)
+	store.blockWrites = make(chan struct{})
+
+	require.NoError(t, w.Put(ctx, []byte("key1"), []byte("value1"), Options{}))
+	w.flushActiveTable()
+	require.NoError(t, w.Put(ctx, []byte("key2"), []byte("value2"), Options{}))
+
+	// A writer waiting on the active table is released by Close
+	awaitErr := make(chan error)
+	go func() {
+		awaitErr <- w.Put(ctx, []byte("key3"), []byte("value3"), Options{AwaitFlush: true})
+	}()
+	require.Eventually(t, func() bool {
+		w.mu.RLock()
+		defer w.mu.RUnlock()
+		return w.activeTable.skl.Len() == 2
+	}, 5*time.Second, time.Millisecond)
+
+	// Close returns the context error if the flushes do not complete in time
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, w.Close(timeout), context.DeadlineExceeded)
+
+	close(store.blockWrites)
+	require.NoError(t, <-awaitErr)
+	assert.Equal(t, uint64(3), w.LastId())
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	assert.Equal(t, []string{walPath(1), walPath(2), walPath(3)}, store.names)
+}
+
+func TestCloseReturnsFlushError(t *testing.T) {
+	ctx := context.Background()
+	errStore := errors.New("store unavailable")
+	store := newMockStore()
+	w, err := NewWAL(Config{
+		Store:         store,
+		FlushInterval: time.Hour,
+		SSTable:       testSSTableConfig,
+		FlushRetries:  1,
+		FlushBackoff:  time.Millisecond,
+		OnFlush:       discard,
+	})
+	require.NoError(t, err)
+	store.syncErr = errStore
+
+	require.NoError(t, w.Put(ctx, []byte("key1"), []byte("value1"), Options{}))
+	err = w.Close(ctx)
+	assert.ErrorIs(t, err, ErrReadOnly)
+	assert.ErrorIs(t, err, errStore)
+}
+
+func TestCloseReleasesStalledWriters(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	w, err := NewWAL(Config{
+		Store:              store,
+		FlushInterval:      time.Hour,
+		SSTable:            testSSTableConfig,
+		MaxUnflushedTables: 1,
+		OnFlush:            discard,
+	})
+	require.NoError(t, err)
+	store.blockWrites = make(chan struct{})
+	defer close(store.blockWrites)
+
+	require.NoError(t, w.Put(ctx, []byte("key1"), []byte("value1"), Options{}))
+	w.flushActiveTable()
+
+	stalled := make(chan error)
+	go func() {
+		stalled <- w.Put(ctx, []byte("key2"), []byte("value2"), Options{})
+	}()
+
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_ = w.Close(timeout)
+
+	select {
+	case err := <-stalled:
+		assert.ErrorIs(t, err, ErrClosed)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for stalled writer")
+	}
+}
+
+func TestCloseStopsFlushBackoff(t *testing.T) {
+	ctx := context.Background()
+	errStore := errors.New("store unavailable")
+	store := newMockStore()
+	w, err := NewWAL(Config{
+		Store:         store,
+		FlushInterval: time.Hour,
+		SSTable:       testSSTableConfig,
+		FlushRetries:  5,
+		FlushBackoff:  time.Hour,
+		OnFlush:       discard,
+	})
+	require.NoError(t, err)
+	store.syncErr = errStore
+
+	// Close does not wait for the backoff before the next retry
+	require.NoError(t, w.Put(ctx, []byte("key1"), []byte("value1"), Options{}))
+	timeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	err = w.Close(timeout)
+	assert.ErrorIs(t, err, ErrReadOnly)
+	assert.ErrorIs(t, err, errStore)
+}
@@ -0,0 +1,64 @@
+package wal
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/thrawn01/lsm-go/internal/sstable"
+	"github.com/thrawn01/lsm-go/objstore"
+)
+
+// fence claims a writer epoch one greater than the epoch of any recovered table, by writing
+// a table without any writes, stamped with the new epoch, as the next WAL SSTable using
+// Bucket.PutIfNotExists. Once the fence is written, any older WAL attempting to flush
+// finds an object already exists with the id it expects to write, and is fenced.
+//
+// If another WAL writes the next WAL SSTable before the fence is written, the new tables are
+// recovered and the fence is written again with the next available id and a newer epoch.
+// The caller must call WAL.recover() before calling fence.
+func (w *WAL) fence() error {
+	for {
+		epoch := w.epoch + 1
+		data, err := serializeKVTable(newKVTable(), w.conf.SSTable, epoch)
+		if err != nil {
+			return fmt.Errorf("while encoding WAL fence: %w", err)
+		}
+
+		err = w.conf.Store.PutIfNotExists(walPath(w.nextId), data)
+		if err == nil {
+			if err := w.conf.Store.Sync(); err != nil {
+				return fmt.Errorf("while syncing WAL object '%s': %w", walPath(w.nextId), err)
+			}
+			w.epoch = epoch
+			w.lastId.Store(w.nextId)
+			w.nextId++
+			return nil
+		}
+		if !errors.Is(err, ErrAlreadyExists) {
+			return fmt.Errorf("while writing WAL object '%s': %w", walPath(w.nextId), err)
+		}
+
+		// Another WAL has written to the store since we recovered
+		if err := w.recover(); err != nil {
+			return err
+		}
+	}
+}
+
+// checkFenced is called when an object already exists with the id of a table being flushed.
+// Returns nil if the existing object was written by this WAL, such as when a previous attempt
+// to write the table succeeded but returned an error, else returns ErrFenced.
+func (w *WAL) checkFenced(id uint64) error {
+	// Only the info of the existing object is needed to identify the writer
+	decoder := &sstable.Decoder{Config: w.conf.SSTable}
+	info, err := decoder.ReadInfo(objstore.NewBlob(w.conf.Store, walPath(id)))
+	if err != nil {
+		return err
+	}
+
+	if info.WriterEpoch != w.epoch {
+		return fmt.Errorf("%w: object was written by writer epoch %d, this WAL has epoch %d",
+			ErrFenced, info.WriterEpoch, w.epoch)
+	}
+	return nil
+}
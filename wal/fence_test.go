@@ -0,0 +1,88 @@
+package wal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFenceZombieWriter(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	conf := Config{Store: store, FlushInterval: time.Hour, SSTable: testSSTableConfig, FlushBackoff: time.Millisecond, OnFlush: discard}
+
+	zombie, err := NewWAL(conf)
+	require.NoError(t, err)
+	defer func() { _ = zombie.Close(context.Background()) }()
+	assert.Equal(t, uint64(1), zombie.Epoch())
+
+	require.NoError(t, zombie.Put(ctx, []byte("key1"), []byte("value1"), Options{}))
+	flushAndWait(t, zombie)
+
+	// A new writer recovers the writes of the zombie and fences it
+	var tables tableCollector
+	conf.OnFlush = tables.OnFlush
+	w, err := NewWAL(conf)
+	require.NoError(t, err)
+	defer func() { _ = w.Close(context.Background()) }()
+	assert.Equal(t, uint64(2), w.Epoch())
+	assert.Equal(t, []uint64{2}, tables.Ids())
+
+	// The zombie fails to flush once fenced, and rejects further writes
+	require.NoError(t, zombie.Put(ctx, []byte("key2"), []byte("value2"), Options{}))
+	zombie.mu.RLock()
+	table := zombie.activeTable
+	zombie.mu.RUnlock()
+	zombie.flushActiveTable()
+	err = table.awaitDurable(ctx)
+	assert.ErrorIs(t, err, ErrFenced)
+	assert.ErrorIs(t, err, ErrReadOnly)
+	assert.ErrorIs(t, zombie.Put(ctx, []byte("key3"), []byte("value3"), Options{}), ErrFenced)
+
+	// The zombie did not overwrite the fence and the new writer continues to write
+	require.NoError(t, w.Put(ctx, []byte("key2"), []byte("value2-new"), Options{}))
+	flushAndWait(t, w)
+	assert.Equal(t, []uint64{2, 4}, tables.Ids())
+	v, ok := tables.Get([]byte("key2"))
+	require.True(t, ok)
+	assert.Equal(t, []byte("value2-new"), v.Value)
+
+	// Every WAL SSTable is stamped with the epoch of the writer
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	require.Equal(t, []string{walPath(1), walPath(2), walPath(3), walPath(4)}, store.names)
+	for i, epoch := range []uint64{1, 1, 2, 2} {
+		_, info, err := decodeEntries(store.names[i], store.objects[store.names[i]], testSSTableConfig)
+		require.NoError(t, err)
+		assert.Equal(t, epoch, info.WriterEpoch, store.names[i])
+	}
+}
+
+func TestFenceRecoversConcurrentWrites(t *testing.T) {
+	store := newMockStore()
+	var tables tableCollector
+	w := &WAL{
+		conf:        Config{Store: store, SSTable: testSSTableConfig, OnFlush: tables.OnFlush},
+		activeTable: newKVTable(),
+		flushedCh:   make(chan struct{}),
+		nextId:      1,
+	}
+	require.NoError(t, w.recover())
+
+	// Another writer flushes a table after the WAL recovered, but before it wrote the fence
+	other := newKVTable()
+	other.set([]byte("key1"), ValueDeletable{Value: []byte("value1"), Seq: 7})
+	data, err := serializeKVTable(other, testSSTableConfig, 3)
+	require.NoError(t, err)
+	require.NoError(t, store.Put(walPath(1), data))
+
+	require.NoError(t, w.fence())
+	assert.Equal(t, uint64(4), w.Epoch())
+	assert.Equal(t, uint64(2), w.LastId())
+	assert.Equal(t, uint64(7), w.lastSeq)
+	assert.Equal(t, []uint64{1}, tables.Ids())
+	assert.Equal(t, []string{walPath(1), walPath(2)}, store.names)
+}
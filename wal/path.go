@@ -0,0 +1,54 @@
+package wal
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/thrawn01/lsm-go/objstore"
+)
+
+const (
+	// walPrefix is the directory in the object store WAL SSTables are written to
+	walPrefix = "wal/"
+	walSuffix = ".sst"
+)
+
+// walPath returns the object store path of the WAL SSTable with the provided id. The id
+// is zero padded such that a lexicographical listing of the paths is also in id order.
+//
+// Example: wal/00000000000000000042.sst
+func walPath(id uint64) string {
+	return fmt.Sprintf("%s%020d%s", walPrefix, id, walSuffix)
+}
+
+// parseWalPath returns the id of the WAL SSTable with the provided path, returns
+// false if the path is not that of a WAL SSTable.
+func parseWalPath(path string) (uint64, bool) {
+	if !strings.HasPrefix(path, walPrefix) || !strings.HasSuffix(path, walSuffix) {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(path, walPrefix), walSuffix), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
+
+// listIds returns the ids of all WAL SSTables in the store in ascending order
+func listIds(store objstore.Bucket) ([]uint64, error) {
+	paths, err := store.List(walPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("while listing WAL objects: %w", err)
+	}
+
+	var ids []uint64
+	for _, path := range paths {
+		if id, ok := parseWalPath(path); ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
@@ -0,0 +1,44 @@
+package wal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalPath(t *testing.T) {
+	assert.Equal(t, "wal/00000000000000000042.sst", walPath(42))
+
+	for _, tt := range []struct {
+		path string
+		id   uint64
+		ok   bool
+	}{
+		{path: walPath(1), id: 1, ok: true},
+		{path: walPath(42), id: 42, ok: true},
+		{path: "wal/18446744073709551615.sst", id: 18446744073709551615, ok: true},
+		{path: "00000000000000000042.sst", ok: false},
+		{path: "wal/00000000000000000042", ok: false},
+		{path: "compacted/00000000000000000042.sst", ok: false},
+		{path: "wal/manifest.sst", ok: false},
+	} {
+		t.Run(tt.path, func(t *testing.T) {
+			id, ok := parseWalPath(tt.path)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.id, id)
+		})
+	}
+}
+
+func TestListIds(t *testing.T) {
+	store := newMockStore()
+	for _, id := range []uint64{3, 1, 100, 2} {
+		require.NoError(t, store.Put(walPath(id), []byte{}))
+	}
+	require.NoError(t, store.Put("wal/unknown", []byte{}))
+
+	ids, err := listIds(store)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 2, 3, 100}, ids)
+}
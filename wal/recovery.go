@@ -0,0 +1,125 @@
+package wal
+
+import (
+	"fmt"
+
+	"github.com/thrawn01/lsm-go/internal/sstable"
+	"github.com/thrawn01/lsm-go/internal/sstable/types"
+)
+
+// recover lists the tables flushed to the object store with an id of at least nextId, and
+// decodes them in id order, handing each table off to Config.OnFlush. Since the recovered
+// tables are already durable, they are not flushed again. New writes are assigned sequence
+// numbers following the highest sequence number recovered.
+//
+// Tables without any writes, such as those written by WAL.fence(), are not handed off.
+func (w *WAL) recover() error {
+	ids, err := listIds(w.conf.Store)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if id < w.nextId {
+			continue
+		}
+
+		data, err := w.conf.Store.Get(walPath(id))
+		if err != nil {
+			return fmt.Errorf("while reading WAL object '%s': %w", walPath(id), err)
+		}
+
+		table, info, err := deserializeKVTable(walPath(id), data, w.conf.SSTable)
+		if err != nil {
+			return err
+		}
+		table.id = id
+		table.markDurable(nil)
+		if table.skl.Len() != 0 {
+			w.conf.OnFlush(table)
+		}
+		w.nextId = id + 1
+		w.lastId.Store(id)
+		w.lastSeq = max(w.lastSeq, table.MaxSeq())
+		w.epoch = max(w.epoch, info.WriterEpoch)
+	}
+	return nil
+}
+
+// deserializeKVTable decodes the SSTable produced by serializeKVTable into a KVTable,
+// and returns the sstable.Info of the SSTable
+func deserializeKVTable(name string, data []byte, conf sstable.Config) (*KVTable, *sstable.Info, error) {
+	entries, info, err := decodeEntries(name, data, conf)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	table := newKVTable()
+	for _, kv := range entries {
+		table.set(kv.Key, ValueDeletable{
+			Value:    kv.Value.Value,
+			IsDelete: kv.Value.IsTombstone,
+			IsMerge:  kv.Value.IsMerge,
+			Seq:      kv.Seq,
+			ExpireAt: kv.Value.ExpireAt,
+		})
+	}
+	return table, info, nil
+}
+
+// decodeEntries decodes every entry in the SSTable produced by serializeKVTable in key
+// order, and returns the sstable.Info of the SSTable
+func decodeEntries(name string, data []byte, conf sstable.Config) ([]types.KeyValue, *sstable.Info, error) {
+	blob := &bytesBlob{id: name, data: data}
+	decoder := &sstable.Decoder{Config: conf}
+
+	info, err := decoder.ReadInfo(blob)
+	if err != nil {
+		return nil, nil, fmt.Errorf("while reading WAL object '%s': %w", name, err)
+	}
+
+	index, err := decoder.ReadIndex(info, blob)
+	if err != nil {
+		return nil, nil, fmt.Errorf("while reading WAL object '%s': %w", name, err)
+	}
+
+	var entries []types.KeyValue
+	iter := sstable.NewIterator(decoder, info, index, blob)
+	for {
+		kv, ok := iter.NextEntry()
+		if !ok {
+			break
+		}
+		entries = append(entries, kv)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, nil, fmt.Errorf("while reading WAL object '%s': %w", name, err)
+	}
+	return entries, info, nil
+}
+
+// bytesBlob is a sstable.ReadOnlyBlob of an object read from the Bucket
+type bytesBlob struct {
+	id   string
+	data []byte
+}
+
+func (b *bytesBlob) Len() (uint64, error) {
+	return uint64(len(b.data)), nil
+}
+
+func (b *bytesBlob) ReadRange(r sstable.Range) ([]byte, error) {
+	if r.Start > r.End || r.End > uint64(len(b.data)) {
+		return nil, fmt.Errorf("range [%d, %d) is outside of object '%s' with length %d",
+			r.Start, r.End, b.id, len(b.data))
+	}
+	return b.data[r.Start:r.End], nil
+}
+
+func (b *bytesBlob) Read() ([]byte, error) {
+	return b.data, nil
+}
+
+func (b *bytesBlob) Id() string {
+	return b.id
+}
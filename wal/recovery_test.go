@@ -0,0 +1,292 @@
+package wal
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/lsm-go/internal/s3fake"
+	"github.com/thrawn01/lsm-go/internal/sstable/block"
+	"github.com/thrawn01/lsm-go/objstore"
+)
+
+// writeAndFlush writes the provided values directly to the active table, then flushes the
+// active table and waits for it to become durable.
+func writeAndFlush(t *testing.T, w *WAL, values map[string]ValueDeletable) {
+	t.Helper()
+	w.mu.Lock()
+	table := w.activeTable
+	for k, v := range values {
+		table.skl.Set([]byte(k), v)
+	}
+	w.mu.Unlock()
+
+	w.flushActiveTable()
+	select {
+	case <-table.isDurableCh:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for table to flush")
+	}
+}
+
+// tableCollector collects the tables handed off to Config.OnFlush
+type tableCollector struct {
+	mu     sync.Mutex
+	tables []*KVTable
+}
+
+func (c *tableCollector) OnFlush(table *KVTable) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tables = append(c.tables, table)
+}
+
+// Get returns the most recent value of the key from the collected tables
+func (c *tableCollector) Get(key []byte) (ValueDeletable, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := len(c.tables) - 1; i >= 0; i-- {
+		if v, ok := c.tables[i].Get(key); ok {
+			return v, true
+		}
+	}
+	return ValueDeletable{}, false
+}
+
+func (c *tableCollector) Ids() []uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var ids []uint64
+	for _, t := range c.tables {
+		ids = append(ids, t.Id())
+	}
+	return ids
+}
+
+func TestRecovery(t *testing.T) {
+	store := newMockStore()
+	conf := Config{Store: store, FlushInterval: time.Hour, SSTable: testSSTableConfig, OnFlush: discard}
+
+	w, err := NewWAL(conf)
+	require.NoError(t, err)
+
+	writeAndFlush(t, w, map[string]ValueDeletable{
+		"key1": {Value: []byte("value1")},
+		"key2": {Value: []byte("value2")},
+		"key3": {Value: []byte("value3")},
+	})
+	writeAndFlush(t, w, map[string]ValueDeletable{
+		"key2": {IsDelete: true},
+		"key3": {Value: []byte("value3-updated")},
+	})
+	// The first WAL SSTable is the fence written by NewWAL()
+	assert.Equal(t, []string{walPath(1), walPath(2), walPath(3)}, store.names)
+	assert.Equal(t, uint64(3), w.LastId())
+
+	// Simulate a crash by abandoning the WAL without calling Close()
+	var recoveredTables tableCollector
+	conf.OnFlush = recoveredTables.OnFlush
+	recovered, err := NewWAL(conf)
+	require.NoError(t, err)
+	defer func() { _ = recovered.Close(context.Background()) }()
+	assert.Equal(t, uint64(4), recovered.LastId())
+	assert.Equal(t, uint64(5), recovered.NextId())
+
+	// Recovered tables are handed off in id order, except for the
+	// fences which contain no writes
+	assert.Equal(t, []uint64{2, 3}, recoveredTables.Ids())
+
+	v, ok := recoveredTables.Get([]byte("key1"))
+	require.True(t, ok)
+	assert.Equal(t, ValueDeletable{Value: []byte("value1")}, v)
+
+	v, ok = recoveredTables.Get([]byte("key2"))
+	require.True(t, ok)
+	assert.True(t, v.IsDelete)
+
+	v, ok = recoveredTables.Get([]byte("key3"))
+	require.True(t, ok)
+	assert.Equal(t, ValueDeletable{Value: []byte("value3-updated")}, v)
+
+	// New flushes must not overwrite the recovered tables
+	writeAndFlush(t, recovered, map[string]ValueDeletable{
+		"key4": {Value: []byte("value4")},
+	})
+	assert.Equal(t, []string{walPath(1), walPath(2), walPath(3), walPath(4), walPath(5)}, store.names)
+	assert.Equal(t, uint64(5), recovered.LastId())
+	assert.Equal(t, []uint64{2, 3, 5}, recoveredTables.Ids())
+}
+
+func TestRecoveryEmptyStore(t *testing.T) {
+	w, err := NewWAL(Config{Store: newMockStore(), FlushInterval: time.Hour, SSTable: testSSTableConfig, OnFlush: discard})
+	require.NoError(t, err)
+	defer func() { _ = w.Close(context.Background()) }()
+
+	// Only the fence has been written
+	assert.Equal(t, uint64(1), w.LastId())
+	assert.Equal(t, uint64(2), w.NextId())
+	assert.Equal(t, uint64(1), w.Epoch())
+}
+
+func TestRecoveryObjectStores(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		// newStore returns a function which opens the same store each time it is called
+		newStore func(t *testing.T) func() objstore.Bucket
+	}{
+		{
+			name: "FileStore",
+			newStore: func(t *testing.T) func() objstore.Bucket {
+				dir := t.TempDir()
+				return func() objstore.Bucket {
+					store, err := objstore.NewFileStore(dir)
+					require.NoError(t, err)
+					return store
+				}
+			},
+		},
+		{
+			name: "S3Store",
+			newStore: func(t *testing.T) func() objstore.Bucket {
+				server := httptest.NewServer(s3fake.New("bucket"))
+				t.Cleanup(server.Close)
+				return func() objstore.Bucket {
+					store, err := objstore.NewS3Store(objstore.S3Config{
+						Endpoint:    server.URL,
+						Bucket:      "bucket",
+						Credentials: objstore.Credentials{AccessKeyID: "id", SecretAccessKey: "secret"},
+					})
+					require.NoError(t, err)
+					return store
+				}
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			open := tt.newStore(t)
+
+			w, err := NewWAL(Config{Store: open(), FlushInterval: time.Hour, SSTable: testSSTableConfig, OnFlush: discard})
+			require.NoError(t, err)
+			require.NoError(t, w.Put(ctx, []byte("key1"), []byte("value1"), Options{}))
+			flushAndWait(t, w)
+			require.NoError(t, w.Put(ctx, []byte("key2"), []byte("value2"), Options{}))
+			require.NoError(t, w.Close(ctx))
+
+			// A WAL opened on a new instance of the store recovers every write
+			var tables tableCollector
+			r, err := NewWAL(Config{Store: open(), FlushInterval: time.Hour, SSTable: testSSTableConfig, OnFlush: tables.OnFlush})
+			require.NoError(t, err)
+			defer func() { _ = r.Close(ctx) }()
+			assert.Equal(t, []uint64{2, 3}, tables.Ids())
+			assert.Equal(t, uint64(2), r.Epoch())
+			for key, value := range map[string]string{"key1": "value1", "key2": "value2"} {
+				v, ok := tables.Get([]byte(key))
+				require.True(t, ok, "key '%s'", key)
+				assert.Equal(t, []byte(value), v.Value)
+			}
+		})
+	}
+}
+
+func TestRecoveryFaults(t *testing.T) {
+	errStore := errors.New("store unavailable")
+
+	for _, tt := range []struct {
+		name  string
+		fault objstore.Fault
+		// err is the expected error, nil if recovery is expected to fail with any error
+		err error
+		ok  bool
+	}{
+		{
+			name:  "SlowRead",
+			fault: objstore.Fault{Op: objstore.OpRead, Latency: 10 * time.Millisecond},
+			ok:    true,
+		},
+		{
+			name:  "ListError",
+			fault: objstore.Fault{Op: objstore.OpList, Err: errStore},
+			err:   errStore,
+		},
+		{
+			name:  "ReadError",
+			fault: objstore.Fault{Op: objstore.OpRead, Path: walPath(2), Err: errStore},
+			err:   errStore,
+		},
+		{
+			name:  "TornTail",
+			fault: objstore.Fault{Op: objstore.OpWrite, Path: walPath(2), TornTail: 7},
+		},
+		{
+			// Flip a bit in the first block
+			name:  "BitFlip",
+			fault: objstore.Fault{Op: objstore.OpWrite, Path: walPath(2), BitFlips: []uint64{3}},
+			err:   block.ErrChecksumFailed,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := objstore.NewMemoryStore()
+			conf := Config{Store: store, FlushInterval: time.Hour, SSTable: testSSTableConfig, OnFlush: discard}
+			w, err := NewWAL(conf)
+			require.NoError(t, err)
+			if tt.fault.Op == objstore.OpWrite {
+				store.Inject(tt.fault)
+			}
+			require.NoError(t, w.Put(ctx, []byte("key1"), []byte("value1"), Options{}))
+			require.NoError(t, w.Close(ctx))
+			store.ClearFaults()
+			if tt.fault.Op != objstore.OpWrite {
+				store.Inject(tt.fault)
+			}
+
+			var tables tableCollector
+			conf.OnFlush = tables.OnFlush
+			r, err := NewWAL(conf)
+			if tt.ok {
+				require.NoError(t, err)
+				defer func() { _ = r.Close(ctx) }()
+				v, ok := tables.Get([]byte("key1"))
+				require.True(t, ok)
+				assert.Equal(t, []byte("value1"), v.Value)
+				return
+			}
+			require.Error(t, err)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+			}
+			if tt.fault.Op != objstore.OpList {
+				assert.Contains(t, err.Error(), walPath(2))
+			}
+		})
+	}
+}
+
+func TestRecoveryIgnoresUnknownObjects(t *testing.T) {
+	store := newMockStore()
+	require.NoError(t, store.Put("manifest/00000000000000000001.manifest", []byte("not a wal table")))
+	require.NoError(t, store.Put("wal/abc.sst", []byte("not a wal table")))
+	require.NoError(t, store.Put("compacted/00000000000000000001.sst", []byte("not a wal table")))
+
+	var tables tableCollector
+	w, err := NewWAL(Config{Store: store, FlushInterval: time.Hour, SSTable: testSSTableConfig, OnFlush: tables.OnFlush})
+	require.NoError(t, err)
+	defer func() { _ = w.Close(context.Background()) }()
+	assert.Empty(t, tables.Ids())
+	assert.Equal(t, uint64(1), w.LastId())
+}
+
+func TestRecoveryCorruptedTable(t *testing.T) {
+	store := newMockStore()
+	require.NoError(t, store.Put(walPath(1), []byte{0x01}))
+
+	_, err := NewWAL(Config{Store: store, FlushInterval: time.Hour, SSTable: testSSTableConfig, OnFlush: discard})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), walPath(1))
+}
@@ -0,0 +1,116 @@
+package wal
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/huandu/skiplist"
+	"github.com/thrawn01/lsm-go/internal/iterator"
+	"github.com/thrawn01/lsm-go/internal/sstable/types"
+)
+
+// Scan returns an Iterator over the keys from start (inclusive) to end (exclusive) in the
+// tables which have not yet been handed off to Config.OnFlush. A nil start scans from the
+// first key and a nil end scans to the last key. When a key is in more than one table, the
+// value from the most recent table is returned, merge operands are resolved as in WAL.Get().
+//
+// The Iterator reads from a snapshot of the tables taken when Scan is called, writes made
+// after Scan returns are not seen by the Iterator.
+func (w *WAL) Scan(start, end []byte) (*Iterator, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return nil, ErrClosed
+	}
+
+	// The active table is modified by writers, so the entries in range are copied while
+	// holding the lock. Immutable tables are never modified and are read as the Iterator
+	// advances.
+	var active []types.KeyValue
+	for e := findElement(w.activeTable, start); e != nil && inRange(e, end); e = e.Next() {
+		active = append(active, toKeyValue(e))
+	}
+
+	sources := []iterator.Source{iterator.NewSliceSource(active...)}
+	for i := len(w.immutableTables) - 1; i >= 0; i-- {
+		sources = append(sources, &tableSource{elem: findElement(w.immutableTables[i], start), end: end})
+	}
+	return newIterator(w.conf.MergeOperator, sources), nil
+}
+
+// Iterator iterates through the keys of a WAL in ascending key order
+type Iterator struct {
+	merged *iterator.MergeIterator
+}
+
+// newIterator returns an Iterator over the sources, which are ordered from newest to oldest
+func newIterator(op MergeOperator, sources []iterator.Source) *Iterator {
+	return &Iterator{
+		merged: iterator.NewMergeIterator(iterator.MergeConfig{MergeOperator: op}, sources...),
+	}
+}
+
+// Next returns the next key value pair, skipping deleted and expired keys. Merge operands
+// are combined with the most recent value of the key, if the tables do not contain a value
+// for the key, the operands are combined as if the key has no value.
+func (iter *Iterator) Next() (types.KV, bool) {
+	return iter.merged.Next()
+}
+
+// NextEntry returns the most recent entry of the next key, including tombstones and expired
+// values. Merge operands are combined with the most recent value of the key, if the tables
+// do not contain a value for the key, the operands are combined into a single merge operand
+// which must be resolved with the value of the key from an older source.
+func (iter *Iterator) NextEntry() (types.KeyValue, bool) {
+	return iter.merged.NextEntry()
+}
+
+// Err returns the error returned by Config.MergeOperator which stopped the iteration, if any
+func (iter *Iterator) Err() error {
+	if err := iter.merged.Err(); err != nil {
+		return fmt.Errorf("while scanning WAL: %w", err)
+	}
+	return nil
+}
+
+// tableSource returns the entries of an immutable table up to end (exclusive)
+type tableSource struct {
+	elem *skiplist.Element
+	end  []byte
+}
+
+func (s *tableSource) NextEntry() (types.KeyValue, bool) {
+	if s.elem == nil || !inRange(s.elem, s.end) {
+		return types.KeyValue{}, false
+	}
+	kv := toKeyValue(s.elem)
+	s.elem = s.elem.Next()
+	return kv, true
+}
+
+// findElement returns the first element of the table greater or equal to start
+func findElement(table *KVTable, start []byte) *skiplist.Element {
+	if start == nil {
+		return table.skl.Front()
+	}
+	return table.skl.Find(start)
+}
+
+// inRange returns true if the key of the element is before end, a nil end has no bound
+func inRange(e *skiplist.Element, end []byte) bool {
+	return end == nil || bytes.Compare(e.Key().([]byte), end) < 0
+}
+
+func toKeyValue(e *skiplist.Element) types.KeyValue {
+	vd := e.Value.(ValueDeletable)
+	return types.KeyValue{
+		Key: e.Key().([]byte),
+		Value: types.Value{
+			Value:       vd.Value,
+			IsTombstone: vd.IsDelete,
+			IsMerge:     vd.IsMerge,
+			ExpireAt:    vd.ExpireAt,
+		},
+		Seq: vd.Seq,
+	}
+}
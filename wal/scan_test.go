@@ -0,0 +1,150 @@
+package wal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/lsm-go/internal/sstable/types"
+)
+
+// scanAll returns every key value pair returned by the iterator
+func scanAll(t *testing.T, iter *Iterator) map[string]string {
+	t.Helper()
+	result := make(map[string]string)
+	var last []byte
+	for {
+		kv, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if last != nil {
+			require.Less(t, string(last), string(kv.Key), "keys must be in ascending order")
+		}
+		last = kv.Key
+		result[string(kv.Key)] = string(kv.Value)
+	}
+	require.NoError(t, iter.Err())
+	return result
+}
+
+func TestScan(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	w, err := NewWAL(Config{Store: store, FlushInterval: time.Hour, SSTable: testSSTableConfig,
+		MergeOperator: appendOperator{}, OnFlush: discard})
+	require.NoError(t, err)
+	// Block writes such that flushed tables remain in the immutable tables
+	store.blockWrites = make(chan struct{})
+	defer func() {
+		close(store.blockWrites)
+		_ = w.Close(ctx)
+	}()
+
+	write := func(ops func(b *WriteBatch)) {
+		var b WriteBatch
+		ops(&b)
+		require.NoError(t, w.Write(ctx, &b, Options{}))
+	}
+
+	write(func(b *WriteBatch) {
+		b.Put([]byte("key1"), []byte("value1"))
+		b.Put([]byte("key2"), []byte("value2"))
+		b.Put([]byte("key3"), []byte("value3"))
+		b.Put([]byte("key4"), []byte("a"))
+	})
+	w.flushActiveTable()
+	write(func(b *WriteBatch) {
+		b.Put([]byte("key2"), []byte("value2-new"))
+		b.Delete([]byte("key3"))
+		b.Merge([]byte("key4"), []byte("b"))
+		b.Merge([]byte("key5"), []byte("a"))
+	})
+	w.flushActiveTable()
+	write(func(b *WriteBatch) {
+		b.Put([]byte("key0"), []byte("value0"))
+		b.Merge([]byte("key4"), []byte("c"))
+		b.Merge([]byte("key5"), []byte("b"))
+		b.Put([]byte("key6"), []byte("value6"))
+	})
+
+	for _, tt := range []struct {
+		name       string
+		start, end []byte
+		expected   map[string]string
+	}{
+		{
+			name: "All",
+			expected: map[string]string{
+				"key0": "value0",
+				"key1": "value1",
+				"key2": "value2-new",
+				"key4": "a,b,c",
+				"key5": "a,b",
+				"key6": "value6",
+			},
+		},
+		{
+			name:     "StartInclusiveEndExclusive",
+			start:    []byte("key2"),
+			end:      []byte("key5"),
+			expected: map[string]string{"key2": "value2-new", "key4": "a,b,c"},
+		},
+		{
+			name:     "StartBetweenKeys",
+			start:    []byte("key35"),
+			expected: map[string]string{"key4": "a,b,c", "key5": "a,b", "key6": "value6"},
+		},
+		{
+			name:     "NoKeysInRange",
+			start:    []byte("key7"),
+			expected: map[string]string{},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			iter, err := w.Scan(tt.start, tt.end)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, scanAll(t, iter))
+		})
+	}
+
+	// NextEntry returns tombstones, and operands without a value as a single merge operand
+	iter, err := w.Scan([]byte("key3"), []byte("key6"))
+	require.NoError(t, err)
+	var entries []types.KeyValue
+	for {
+		kv, ok := iter.NextEntry()
+		if !ok {
+			break
+		}
+		entries = append(entries, kv)
+	}
+	assert.Equal(t, []types.KeyValue{
+		{Key: []byte("key3"), Value: types.Value{IsTombstone: true}, Seq: 2},
+		{Key: []byte("key4"), Value: types.Value{Value: []byte("a,b,c")}, Seq: 3},
+		{Key: []byte("key5"), Value: types.Value{Value: []byte("a,b"), IsMerge: true}, Seq: 3},
+	}, entries)
+}
+
+func TestScanSnapshot(t *testing.T) {
+	ctx := context.Background()
+	w, err := NewWAL(Config{Store: newMockStore(), FlushInterval: time.Hour, SSTable: testSSTableConfig, OnFlush: discard})
+	require.NoError(t, err)
+	defer func() { _ = w.Close(ctx) }()
+
+	require.NoError(t, w.Put(ctx, []byte("key1"), []byte("value1"), Options{}))
+	require.NoError(t, w.Put(ctx, []byte("key2"), []byte("value2"), Options{}))
+	iter, err := w.Scan(nil, nil)
+	require.NoError(t, err)
+
+	// Writes made after Scan returns are not seen by the iterator
+	require.NoError(t, w.Put(ctx, []byte("key1"), []byte("value1-new"), Options{}))
+	var b WriteBatch
+	b.Put([]byte("key3"), []byte("value3"))
+	b.Delete([]byte("key2"))
+	require.NoError(t, w.Write(ctx, &b, Options{}))
+
+	assert.Equal(t, map[string]string{"key1": "value1", "key2": "value2"}, scanAll(t, iter))
+}
@@ -0,0 +1,190 @@
+package wal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/thrawn01/lsm-go/internal/sstable"
+	"github.com/thrawn01/lsm-go/objstore"
+)
+
+type TailerConfig struct {
+	// Store is the object store the WAL SSTables are read from
+	Store objstore.Bucket
+
+	// SSTable is the config used to decode each WAL SSTable
+	SSTable sstable.Config
+
+	// PollInterval is how often the Store is listed for new WAL SSTables once
+	// the tailer has read every WAL SSTable in the store. Defaults to 1s
+	PollInterval time.Duration
+
+	// StartId is the id of the first WAL SSTable to read, WAL SSTables with a lower id
+	// are skipped. If zero, the tailer starts with the oldest WAL SSTable in the store.
+	StartId uint64
+
+	// StartSeq is the sequence number of the first change to return, changes with a
+	// lower sequence number are skipped. If zero, no changes are skipped.
+	StartSeq uint64
+}
+
+// ErrMissingTable is returned by Tailer.Next when the next WAL SSTable to read is not in the
+// object store but a later WAL SSTable is, such as when TailerConfig.StartId refers to a WAL
+// SSTable which has been garbage collected.
+var ErrMissingTable = errors.New("WAL SSTable is missing from the object store")
+
+// Change is a single put, merge or delete read from a WAL SSTable. A WAL SSTable holds only
+// the newest write of each key written before the table was flushed, so a Change is the
+// newest write of the key in the WAL SSTable, and merge operands written to the key before
+// the table was flushed are already combined into a single Change.
+type Change struct {
+	// Id is the id of the WAL SSTable the change was read from
+	Id uint64
+
+	// Seq is the sequence number assigned by the WAL to the write
+	Seq uint64
+
+	Key      []byte
+	Value    []byte
+	IsDelete bool
+
+	// IsMerge is true if Value is a merge operand written by WAL.Merge()
+	IsMerge bool
+
+	// ExpireAt is the unix time in milliseconds at which the value expires,
+	// zero if the value never expires
+	ExpireAt int64
+}
+
+// Tailer reads the changes in the WAL SSTables flushed to the object store in the order
+// they were written, such that a process other than the writer can follow the writes to
+// the WAL. The Tailer only reads from the object store, and so only returns changes once
+// they have been flushed.
+//
+// Since a WAL SSTable holds only the newest write of each key, writes which were overwritten
+// before the table was flushed are never returned, such that the sequence numbers of the
+// returned changes may have gaps.
+//
+// To resume tailing after a restart, create a new Tailer with TailerConfig.StartId set to
+// the Change.Id and TailerConfig.StartSeq set to one greater than the Change.Seq of the
+// last change processed.
+type Tailer struct {
+	conf TailerConfig
+	// nextId is the lowest id of a WAL SSTable which has not yet been read
+	nextId uint64
+	// ids are the listed ids of the WAL SSTables which have not yet been read, such that
+	// the Store is only listed again once every listed WAL SSTable has been read
+	ids     []uint64
+	pending []Change
+}
+
+// NewTailer returns a Tailer which starts reading from TailerConfig.StartId
+func NewTailer(conf TailerConfig) *Tailer {
+	if conf.PollInterval == 0 {
+		conf.PollInterval = time.Second
+	}
+	return &Tailer{
+		conf:   conf,
+		nextId: conf.StartId,
+	}
+}
+
+// Next returns the next change, blocking until a new WAL SSTable is flushed to the object
+// store if every change has already been returned. Changes are returned in sequence order,
+// writes in the same WriteBatch share a sequence number and are returned in key order.
+// Returns the context error if the context is cancelled before a change is available, or
+// ErrMissingTable if the next WAL SSTable to read is no longer in the object store.
+func (t *Tailer) Next(ctx context.Context) (Change, error) {
+	for len(t.pending) == 0 {
+		found, err := t.poll()
+		if err != nil {
+			return Change{}, err
+		}
+		if found {
+			continue
+		}
+
+		timer := time.NewTimer(t.conf.PollInterval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return Change{}, ctx.Err()
+		}
+	}
+
+	c := t.pending[0]
+	t.pending = t.pending[1:]
+	return c, nil
+}
+
+// poll reads the next WAL SSTable in the store into pending, returning false if
+// there are no WAL SSTables in the store which have not yet been read. Returns
+// ErrMissingTable if a WAL SSTable after the next WAL SSTable is found instead.
+func (t *Tailer) poll() (bool, error) {
+	if len(t.ids) == 0 {
+		ids, err := listIds(t.conf.Store)
+		if err != nil {
+			return false, err
+		}
+		for _, id := range ids {
+			if id >= t.nextId {
+				t.ids = append(t.ids, id)
+			}
+		}
+		if len(t.ids) == 0 {
+			return false, nil
+		}
+	}
+
+	// Ids are assigned in order without gaps, a missing id can not be written later
+	id := t.ids[0]
+	if t.nextId != 0 && id > t.nextId {
+		return false, fmt.Errorf("%w: expected '%s' but found '%s'",
+			ErrMissingTable, walPath(t.nextId), walPath(id))
+	}
+
+	changes, err := t.readChanges(id)
+	if err != nil {
+		return false, err
+	}
+	t.pending = changes
+	t.nextId = id + 1
+	t.ids = t.ids[1:]
+	return true, nil
+}
+
+// readChanges returns the changes in the WAL SSTable in sequence order,
+// skipping changes below TailerConfig.StartSeq
+func (t *Tailer) readChanges(id uint64) ([]Change, error) {
+	data, err := t.conf.Store.Get(walPath(id))
+	if err != nil {
+		return nil, fmt.Errorf("while reading WAL object '%s': %w", walPath(id), err)
+	}
+
+	entries, _, err := decodeEntries(walPath(id), data, t.conf.SSTable)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]Change, 0, len(entries))
+	for _, e := range entries {
+		if e.Seq < t.conf.StartSeq {
+			continue
+		}
+		changes = append(changes, Change{
+			Id:       id,
+			Seq:      e.Seq,
+			Key:      e.Key,
+			Value:    e.Value.Value,
+			IsDelete: e.Value.IsTombstone,
+			IsMerge:  e.Value.IsMerge,
+			ExpireAt: e.Value.ExpireAt,
+		})
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Seq < changes[j].Seq })
+	return changes, nil
+}
@@ -0,0 +1,178 @@
+package wal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flushAndWait flushes the active table and waits for it to become durable
+func flushAndWait(t *testing.T, w *WAL) {
+	t.Helper()
+	w.mu.RLock()
+	table := w.activeTable
+	w.mu.RUnlock()
+
+	w.flushActiveTable()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, table.awaitDurable(ctx))
+}
+
+// tailChanges reads n changes from the tailer
+func tailChanges(t *testing.T, tailer *Tailer, n int) []Change {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var changes []Change
+	for i := 0; i < n; i++ {
+		c, err := tailer.Next(ctx)
+		require.NoError(t, err)
+		changes = append(changes, c)
+	}
+	return changes
+}
+
+func TestTailer(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	w, err := NewWAL(Config{Store: store, FlushInterval: time.Hour, SSTable: testSSTableConfig, OnFlush: discard})
+	require.NoError(t, err)
+	defer func() { _ = w.Close(context.Background()) }()
+
+	require.NoError(t, w.Put(ctx, []byte("key2"), []byte("value2"), Options{}))
+	var b WriteBatch
+	b.Put([]byte("key3"), []byte("value3"))
+	b.Put([]byte("key1"), []byte("value1"))
+	require.NoError(t, w.Write(ctx, &b, Options{}))
+	b.Reset()
+	b.Delete([]byte("key2"))
+	require.NoError(t, w.Write(ctx, &b, Options{}))
+	flushAndWait(t, w)
+	require.NoError(t, w.Put(ctx, []byte("key4"), []byte("value4"), Options{}))
+	flushAndWait(t, w)
+
+	t.Run("FromStart", func(t *testing.T) {
+		tailer := NewTailer(TailerConfig{Store: store, SSTable: testSSTableConfig, PollInterval: time.Millisecond})
+		// The put of key2 was overwritten by the delete before the table was flushed
+		assert.Equal(t, []Change{
+			{Id: 2, Seq: 2, Key: []byte("key1"), Value: []byte("value1")},
+			{Id: 2, Seq: 2, Key: []byte("key3"), Value: []byte("value3")},
+			{Id: 2, Seq: 3, Key: []byte("key2"), IsDelete: true},
+			{Id: 3, Seq: 4, Key: []byte("key4"), Value: []byte("value4")},
+		}, tailChanges(t, tailer, 4))
+	})
+
+	t.Run("FromId", func(t *testing.T) {
+		tailer := NewTailer(TailerConfig{Store: store, SSTable: testSSTableConfig, StartId: 3})
+		assert.Equal(t, []Change{
+			{Id: 3, Seq: 4, Key: []byte("key4"), Value: []byte("value4")},
+		}, tailChanges(t, tailer, 1))
+	})
+
+	t.Run("FromSeq", func(t *testing.T) {
+		tailer := NewTailer(TailerConfig{Store: store, SSTable: testSSTableConfig, StartSeq: 3})
+		assert.Equal(t, []Change{
+			{Id: 2, Seq: 3, Key: []byte("key2"), IsDelete: true},
+			{Id: 3, Seq: 4, Key: []byte("key4"), Value: []byte("value4")},
+		}, tailChanges(t, tailer, 2))
+	})
+
+	t.Run("WaitsForNewTables", func(t *testing.T) {
+		tailer := NewTailer(TailerConfig{Store: store, SSTable: testSSTableConfig,
+			StartId: 4, PollInterval: time.Millisecond})
+
+		done := make(chan Change)
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			c, err := tailer.Next(ctx)
+			assert.NoError(t, err)
+			done <- c
+		}()
+
+		select {
+		case <-done:
+			require.FailNow(t, "tailer returned a change before the table was flushed")
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		require.NoError(t, w.Put(ctx, []byte("key5"), []byte("value5"), Options{}))
+		flushAndWait(t, w)
+		assert.Equal(t, Change{Id: 4, Seq: 5, Key: []byte("key5"), Value: []byte("value5")}, <-done)
+	})
+}
+
+func TestTailerContextCancelled(t *testing.T) {
+	tailer := NewTailer(TailerConfig{Store: newMockStore(), SSTable: testSSTableConfig, PollInterval: time.Millisecond})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := tailer.Next(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestTailerReadError(t *testing.T) {
+	store := newMockStore()
+	require.NoError(t, store.Put(walPath(1), []byte{0x01}))
+
+	tailer := NewTailer(TailerConfig{Store: store, SSTable: testSSTableConfig})
+	_, err := tailer.Next(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), walPath(1))
+}
+
+func TestTailerMissingTable(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	w, err := NewWAL(Config{Store: store, FlushInterval: time.Hour, SSTable: testSSTableConfig, OnFlush: discard})
+	require.NoError(t, err)
+	defer func() { _ = w.Close(context.Background()) }()
+
+	require.NoError(t, w.Put(ctx, []byte("key1"), []byte("value1"), Options{}))
+	flushAndWait(t, w)
+	require.NoError(t, w.Put(ctx, []byte("key2"), []byte("value2"), Options{}))
+	flushAndWait(t, w)
+
+	// The WAL SSTable at the start id and every older WAL SSTable was garbage collected
+	require.NoError(t, store.Delete(walPath(1)))
+	require.NoError(t, store.Delete(walPath(2)))
+	tailer := NewTailer(TailerConfig{Store: store, SSTable: testSSTableConfig, StartId: 2})
+	_, err = tailer.Next(ctx)
+	assert.ErrorIs(t, err, ErrMissingTable)
+	assert.Contains(t, err.Error(), walPath(3))
+
+	// Without a start id the tailer starts with the oldest WAL SSTable in the store
+	tailer = NewTailer(TailerConfig{Store: store, SSTable: testSSTableConfig, PollInterval: time.Millisecond})
+	assert.Equal(t, []Change{
+		{Id: 3, Seq: 2, Key: []byte("key2"), Value: []byte("value2")},
+	}, tailChanges(t, tailer, 1))
+}
+
+func TestTailerListsOnce(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	w, err := NewWAL(Config{Store: store, FlushInterval: time.Hour, SSTable: testSSTableConfig, OnFlush: discard})
+	require.NoError(t, err)
+	defer func() { _ = w.Close(context.Background()) }()
+
+	for _, key := range []string{"key1", "key2", "key3"} {
+		require.NoError(t, w.Put(ctx, []byte(key), []byte("value"), Options{}))
+		flushAndWait(t, w)
+	}
+
+	// Catching up on every WAL SSTable only lists the store once
+	store.mu.Lock()
+	store.lists = 0
+	store.mu.Unlock()
+	tailer := NewTailer(TailerConfig{Store: store, SSTable: testSSTableConfig, PollInterval: time.Millisecond})
+	changes := tailChanges(t, tailer, 3)
+	assert.Equal(t, []byte("key3"), changes[2].Key)
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	assert.Equal(t, 1, store.lists)
+}
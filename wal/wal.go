@@ -1,119 +1,632 @@
 package wal
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"math"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/huandu/skiplist"
+	"github.com/thrawn01/lsm-go/internal/sstable"
+	"github.com/thrawn01/lsm-go/internal/sstable/types"
+	"github.com/thrawn01/lsm-go/objstore"
 )
 
-type ObjectStore interface {
-	Write(data []byte) error
-	Read(offset int64, size int) ([]byte, error)
-	Sync() error
+// MergeOperator combines a merge operand with the existing value of a key, such that values
+// can be updated without first reading the existing value. Merge must be associative, as
+// operands are combined with each other when the existing value is not yet known, in which
+// case existing is the older operand.
+type MergeOperator = types.MergeOperator
+
+type Config struct {
+	// Store is the object store each flushed table is written to. Each WAL SSTable is
+	// written as a separate object with a path in the form `wal/<id>.sst`
+	Store objstore.Bucket
+
+	// FlushInterval is how often the active table is flushed to the Store. Defaults to 100ms
+	FlushInterval time.Duration
+
+	// SSTable is the config used to encode each flushed table as an SSTable. If zero,
+	// SSTable.BlockSize defaults to 4096 and SSTable.FilterBitsPerKey defaults to 10
+	SSTable sstable.Config
+
+	// FlushRetries is the number of times a failed flush is retried before the
+	// WAL gives up and enters the read-only state. Defaults to 3, a negative value
+	// disables retries.
+	FlushRetries int
+
+	// FlushBackoff is how long to wait before the first retry of a failed flush, the
+	// wait is doubled after each subsequent failed attempt. Defaults to 100ms
+	FlushBackoff time.Duration
+
+	// MaxTableSize is the size in bytes of the keys and values in the active table which
+	// triggers an immediate flush of the active table without waiting for FlushInterval.
+	// A single WriteBatch is never split across tables, so a table may exceed MaxTableSize
+	// by the size of the last write. If zero, the active table is only flushed every
+	// FlushInterval.
+	MaxTableSize int64
+
+	// MaxUnflushedBytes is the size in bytes of the keys and values in all tables not yet
+	// flushed to the object store, at which writes stall until flushes catch up.
+	// If zero, writes never stall on the number of unflushed bytes.
+	MaxUnflushedBytes int64
+
+	// MaxUnflushedTables is the number of rotated tables waiting to be flushed to the object
+	// store, at which writes stall until flushes catch up. If zero, writes never stall on
+	// the number of unflushed tables.
+	MaxUnflushedTables int
+
+	// MergeOperator combines the operands written by WAL.Merge() with the existing value
+	// of the key. Merge operands are rejected with ErrNoMergeOperator if nil.
+	MergeOperator MergeOperator
+
+	// OnFlush is called with each table once it is durable in the object store, including
+	// tables recovered from the object store by NewWAL(). Tables are handed off in id order,
+	// after which the table is removed from the WAL and the writes in the table are no longer
+	// returned by WAL.Get(). OnFlush is typically used to hand off the table to a memtable.
+	// OnFlush is required, NewWAL() returns ErrNoOnFlush if nil.
+	OnFlush func(*KVTable)
 }
 
+var (
+	// ErrReadOnly is returned by writes once a table has failed to flush to the object
+	// store. The returned error also wraps the error which caused the flush to fail.
+	ErrReadOnly = errors.New("WAL is read-only after a failed flush")
+
+	// ErrEmptyKey is returned when writing a key with a length of zero
+	ErrEmptyKey = errors.New("key must not be empty")
+
+	// ErrKeyTooLarge is returned when writing a key which is too large to be encoded in an SSTable
+	ErrKeyTooLarge = fmt.Errorf("key must not exceed %d bytes", math.MaxUint16)
+
+	// ErrValueTooLarge is returned when writing a value which is too large to be encoded in an SSTable
+	ErrValueTooLarge = fmt.Errorf("value must be less than %d bytes", uint32(types.Tombstone))
+
+	// ErrClosed is returned by all operations once the WAL has been closed
+	ErrClosed = errors.New("WAL is closed")
+
+	// ErrWriteStall is returned when the context is cancelled while a write is stalled
+	// waiting for unflushed tables to be flushed to the object store. The returned
+	// error also wraps the context error.
+	ErrWriteStall = errors.New("write stalled waiting for flush")
+
+	// ErrAlreadyExists is returned by Bucket.PutIfNotExists when an object already exists at the path
+	ErrAlreadyExists = objstore.ErrAlreadyExists
+
+	// ErrFenced is returned by writes once another WAL with a newer writer epoch has written to
+	// the object store. The WAL is read-only once fenced, so the returned error also wraps ErrReadOnly.
+	ErrFenced = errors.New("WAL has been fenced by a newer writer")
+
+	// ErrNoOnFlush is returned by NewWAL when Config.OnFlush is nil, as the writes in each
+	// flushed table would otherwise no longer be readable
+	ErrNoOnFlush = errors.New("Config.OnFlush is required")
+
+	// ErrNoMergeOperator is returned when writing a merge operand without a Config.MergeOperator
+	ErrNoMergeOperator = errors.New("merge requires a Config.MergeOperator")
+)
+
 type Options struct {
 	AwaitFlush bool
+
+	// ExpireAt if not zero is the time at which the written values expire, after which they
+	// are treated as if they were deleted. ExpireAt applies to every put and merge in a WriteBatch.
+	ExpireAt time.Time
+}
+
+// expireAt returns Options.ExpireAt as unix time in milliseconds, zero if the values never expire
+func (o Options) expireAt() int64 {
+	if o.ExpireAt.IsZero() {
+		return 0
+	}
+	return o.ExpireAt.UnixMilli()
 }
 
 type ValueDeletable struct {
 	Value    []byte
 	IsDelete bool
+	// IsMerge is true if Value is a merge operand which must be combined with the
+	// older values of the key using Config.MergeOperator.
+	IsMerge bool
+	// Seq is the sequence number assigned by the WAL to the write. Every
+	// write in the same WriteBatch is assigned the same sequence number.
+	Seq uint64
+	// ExpireAt is the unix time in milliseconds at which the value expires,
+	// zero if the value never expires.
+	ExpireAt int64
+}
+
+// IsExpired returns true if the value expires at or before now
+func (v ValueDeletable) IsExpired(now time.Time) bool {
+	return v.ExpireAt != 0 && !v.IsDelete && v.ExpireAt <= now.UnixMilli()
 }
 
 type KVTable struct {
+	id          uint64
 	skl         *skiplist.SkipList
 	size        atomic.Int64
-	isDurableCh chan bool
+	maxSeq      uint64
+	isDurableCh chan struct{}
+	// prev is the table rotated before this table, which must
+	// become durable before this table is written.
+	prev *KVTable
+	// err is the result of flushing the table, it must
+	// only be read after isDurableCh has been closed.
+	err error
 }
 
 type WAL struct {
-	store           ObjectStore
+	conf            Config
 	mu              sync.RWMutex
 	activeTable     *KVTable
 	immutableTables []*KVTable
-	flushInterval   time.Duration
-	stopCh          chan struct{}
+	// nextId is the id assigned to the next table flushed
+	nextId uint64
+	// lastId is the id of the last table written to the store, zero if nothing has been written
+	lastId atomic.Uint64
+	// lastSeq is the sequence number assigned to the last write, zero if nothing has been written
+	lastSeq uint64
+	// epoch is the writer epoch claimed by the WAL, which is recorded in every WAL SSTable it writes
+	epoch uint64
+	// failure is set once a table fails to flush, after which the WAL is read-only
+	failure atomic.Pointer[error]
+	// flushedCh is closed and replaced each time a flush completes to wake stalled writers
+	flushedCh chan struct{}
+	// flushWg tracks the outstanding flushTableToObjectStore goroutines
+	flushWg sync.WaitGroup
+	closed  bool
+	stopCh  chan struct{}
 }
 
-func NewWAL(store ObjectStore, flushInterval time.Duration) *WAL {
+// NewWAL recovers any tables previously flushed to Config.Store, claims a new writer epoch
+// which fences out any other WAL writing to the store, then starts periodically flushing
+// new writes to the store.
+func NewWAL(conf Config) (*WAL, error) {
+	if conf.OnFlush == nil {
+		return nil, ErrNoOnFlush
+	}
+	if conf.FlushInterval == 0 {
+		conf.FlushInterval = 100 * time.Millisecond
+	}
+	if conf.SSTable.BlockSize == 0 {
+		conf.SSTable.BlockSize = 4096
+	}
+	if conf.SSTable.FilterBitsPerKey == 0 {
+		conf.SSTable.FilterBitsPerKey = 10
+	}
+	switch {
+	case conf.FlushRetries == 0:
+		conf.FlushRetries = 3
+	case conf.FlushRetries < 0:
+		conf.FlushRetries = 0
+	}
+	if conf.FlushBackoff == 0 {
+		conf.FlushBackoff = 100 * time.Millisecond
+	}
+
 	wal := &WAL{
-		store:         store,
-		activeTable:   newKVTable(),
-		flushInterval: flushInterval,
-		stopCh:        make(chan struct{}),
+		conf:        conf,
+		activeTable: newKVTable(),
+		flushedCh:   make(chan struct{}),
+		stopCh:      make(chan struct{}),
+		nextId:      1,
+	}
+	if err := wal.recover(); err != nil {
+		return nil, err
+	}
+	if err := wal.fence(); err != nil {
+		return nil, err
 	}
 	go wal.periodicFlush()
-	return wal
+	return wal, nil
 }
 
 func newKVTable() *KVTable {
 	return &KVTable{
 		skl:         skiplist.New(skiplist.BytesAsc),
-		isDurableCh: make(chan bool),
+		isDurableCh: make(chan struct{}),
 	}
 }
 
-func (w *WAL) Put(k []byte, v []byte, opts Options) error {
-	w.mu.Lock()
-	defer w.mu.Unlock()
+// Id returns the id of the WAL SSTable the table is flushed to
+func (t *KVTable) Id() uint64 {
+	return t.id
+}
+
+// Size returns the size in bytes of the keys and values in the table
+func (t *KVTable) Size() int64 {
+	return t.size.Load()
+}
+
+// Get returns the value of the key in the table, returns false if the key is not in the table.
+// Tables passed to Config.OnFlush are immutable and safe to read concurrently.
+func (t *KVTable) Get(key []byte) (ValueDeletable, bool) {
+	e := t.skl.Get(key)
+	if e == nil {
+		return ValueDeletable{}, false
+	}
+	return e.Value.(ValueDeletable), true
+}
 
-	oldSize := len(k) + len(w.activeTable.skl.Get(k).Value.(ValueDeletable).Value)
-	w.activeTable.skl.Set(k, ValueDeletable{Value: v, IsDelete: false})
-	newSize := len(k) + len(v)
-	w.activeTable.size.Add(int64(newSize - oldSize))
+// MaxSeq returns the highest sequence number of the writes in the table, zero if the table is empty
+func (t *KVTable) MaxSeq() uint64 {
+	return t.maxSeq
+}
+
+// Ascend calls fn for each key and value in the table in ascending key order
+// until fn returns false.
+func (t *KVTable) Ascend(fn func(key []byte, value ValueDeletable) bool) {
+	for e := t.skl.Front(); e != nil; e = e.Next() {
+		if !fn(e.Key().([]byte), e.Value.(ValueDeletable)) {
+			return
+		}
+	}
+}
+
+// awaitDurable blocks until the table has been flushed, returning the error which caused
+// the flush to fail, if any. Returns the context error if the context is cancelled first.
+func (t *KVTable) awaitDurable(ctx context.Context) error {
+	select {
+	case <-t.isDurableCh:
+		return t.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// set adds or replaces the value of the key in the table. The size of the table is the
+// sum of the length of each key and value in the table, where a tombstone has no value.
+func (t *KVTable) set(key []byte, value ValueDeletable) {
+	size := int64(len(key) + len(value.Value))
+	if old := t.skl.Get(key); old != nil {
+		size -= int64(len(key) + len(old.Value.(ValueDeletable).Value))
+	}
+	t.skl.Set(key, value)
+	t.size.Add(size)
+	if value.Seq > t.maxSeq {
+		t.maxSeq = value.Seq
+	}
+}
+
+// markDurable notifies everyone waiting on the table that the flush completed with the provided error
+func (t *KVTable) markDurable(err error) {
+	t.err = err
+	close(t.isDurableCh)
+}
+
+// Put writes the key and value to the active table. If Options.AwaitFlush is true, Put
+// blocks until the table containing the write has been flushed to the object store.
+// The key and value are copied, such that the caller may reuse them after Put returns.
+// See WAL.write() for the conditions under which Put stalls.
+func (w *WAL) Put(ctx context.Context, k []byte, v []byte, opts Options) error {
+	table, err := w.write(ctx, opts.expireAt(), batchEntry{
+		key:   append([]byte(nil), k...),
+		value: ValueDeletable{Value: append([]byte(nil), v...)},
+	})
+	if err != nil {
+		return err
+	}
 
 	if opts.AwaitFlush {
-		<-w.activeTable.isDurableCh
+		return table.awaitDurable(ctx)
+	}
+	return nil
+}
+
+// Merge writes a merge operand for the key to the active table. When the key is read, the
+// operand is combined with the existing value of the key using Config.MergeOperator. If the
+// active table already contains the key, the operand is combined with the value in the active
+// table when written, unless the value expires before the operand, in which case the active
+// table is flushed such that the value and the operand are combined when read. If
+// Options.AwaitFlush is true, Merge blocks until the table containing the write has been
+// flushed to the object store. See WAL.write() for the conditions under which Merge stalls.
+func (w *WAL) Merge(ctx context.Context, k []byte, operand []byte, opts Options) error {
+	table, err := w.write(ctx, opts.expireAt(), batchEntry{
+		key:   append([]byte(nil), k...),
+		value: ValueDeletable{Value: append([]byte(nil), operand...), IsMerge: true},
+	})
+	if err != nil {
+		return err
 	}
 
+	if opts.AwaitFlush {
+		return table.awaitDurable(ctx)
+	}
 	return nil
 }
 
-func (w *WAL) Delete(k []byte) error {
+// Delete writes a tombstone for the key to the active table, and blocks until the table
+// containing the tombstone has been flushed to the object store. The tombstone shadows
+// any value of the key in older tables, even if the key is not in the active table.
+// See WAL.write() for the conditions under which Delete stalls.
+func (w *WAL) Delete(ctx context.Context, k []byte) error {
+	table, err := w.write(ctx, 0, batchEntry{
+		key:   append([]byte(nil), k...),
+		value: ValueDeletable{IsDelete: true},
+	})
+	if err != nil {
+		return err
+	}
+	return table.awaitDurable(ctx)
+}
+
+// write adds the entries to the active table and returns the table written to. All entries
+// are added while holding the lock, such that readers see either all or none of the entries
+// and all the entries are flushed in the same table. Every entry is assigned the same
+// sequence number, which is one greater than the sequence number of the previous write.
+// If expireAt is not zero, every put and merge is assigned the expiration time. Callers
+// wait for durability on the returned table without holding the lock, such that all writers
+// waiting on the same table share a single flush (group commit), and the active table can
+// be rotated while they wait.
+//
+// If Config.MaxUnflushedBytes or Config.MaxUnflushedTables has been reached, write blocks
+// until enough tables are flushed, or returns ErrWriteStall if the context is cancelled.
+func (w *WAL) write(ctx context.Context, expireAt int64, entries ...batchEntry) (*KVTable, error) {
+	// Validate every entry before adding any, such that a batch is never partially applied
+	for _, e := range entries {
+		if err := w.validate(e); err != nil {
+			return nil, err
+		}
+	}
+
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	oldSize := len(k) + len(w.activeTable.skl.Get(k).Value.(ValueDeletable).Value)
-	w.activeTable.skl.Set(k, ValueDeletable{IsDelete: true})
-	w.activeTable.size.Add(int64(len(k) - oldSize))
+	for {
+		if w.closed {
+			return nil, ErrClosed
+		}
+		if err := w.readOnlyErr(); err != nil {
+			return nil, err
+		}
+		if !w.isStalled() {
+			break
+		}
+
+		// If the active table alone exceeds the limit, flush it so the stall can clear
+		if len(w.immutableTables) == 0 {
+			w.rotateActiveTable()
+		}
+
+		flushedCh := w.flushedCh
+		w.mu.Unlock()
+		select {
+		case <-flushedCh:
+			w.mu.Lock()
+		case <-ctx.Done():
+			w.mu.Lock()
+			return nil, fmt.Errorf("%w: %w", ErrWriteStall, ctx.Err())
+		}
+	}
+
+	if w.expiresBeforeMerge(entries, expireAt) {
+		w.rotateActiveTable()
+	}
+
+	table := w.activeTable
+	values := make([]ValueDeletable, len(entries))
+	for i, e := range entries {
+		values[i] = e.value
+		values[i].Seq = w.lastSeq + 1
+		if !e.value.IsDelete {
+			values[i].ExpireAt = expireAt
+		}
+	}
+	if err := w.combineMerges(table, entries, values); err != nil {
+		return nil, err
+	}
+
+	w.lastSeq++
+	for i, e := range entries {
+		table.set(e.key, values[i])
+	}
+
+	if w.conf.MaxTableSize > 0 && table.size.Load() >= w.conf.MaxTableSize {
+		w.rotateActiveTable()
+	}
+	return table, nil
+}
+
+// combineMerges combines each merge operand in values with the value of the key written by
+// an earlier entry of the batch, or if there is none, the value of the key in the table.
+// Since the table holds a single value for each key, the operand must be combined with the
+// existing value in the table, else the existing value would be lost. The caller must hold
+// the lock.
+func (w *WAL) combineMerges(table *KVTable, entries []batchEntry, values []ValueDeletable) error {
+	var latest map[string]int
+	if len(entries) > 1 {
+		latest = make(map[string]int, len(entries))
+	}
+
+	now := time.Now()
+	for i, e := range entries {
+		if values[i].IsMerge {
+			existing, ok := table.Get(e.key)
+			if j, found := latest[string(e.key)]; found {
+				existing, ok = values[j], true
+			}
+			if ok {
+				combined, err := w.combine(e.key, existing, values[i], now)
+				if err != nil {
+					return fmt.Errorf("while merging key '%s': %w", e.key, err)
+				}
+				values[i] = combined
+			}
+		}
+		if latest != nil {
+			latest[string(e.key)] = i
+		}
+	}
+	return nil
+}
+
+// expiresBeforeMerge returns true if a merge operand in entries, which expires at expireAt,
+// would be combined with a value in the active table which expires before the operand.
+// Once the value expires, reads apply the operand as if the key has no value, so the value
+// and the operand must remain separate entries in different tables, else the result would
+// depend on whether the table was flushed between the writes. The caller must hold the lock.
+func (w *WAL) expiresBeforeMerge(entries []batchEntry, expireAt int64) bool {
+	now := time.Now()
+	for _, e := range entries {
+		if !e.value.IsMerge {
+			continue
+		}
+		existing, ok := w.activeTable.Get(e.key)
+		if !ok || existing.IsDelete || existing.ExpireAt == 0 || existing.IsExpired(now) {
+			continue
+		}
+		if expireAt == 0 || expireAt > existing.ExpireAt {
+			return true
+		}
+	}
+	return false
+}
+
+// combine returns the result of applying the merge operand to the existing value. If the
+// existing value is also a merge operand, the result is a merge operand.
+func (w *WAL) combine(key []byte, existing, operand ValueDeletable, now time.Time) (ValueDeletable, error) {
+	var base []byte
+	if !existing.IsDelete && !existing.IsExpired(now) {
+		base = existing.Value
+		operand.IsMerge = existing.IsMerge
+	} else {
+		operand.IsMerge = false
+	}
+
+	value, err := w.conf.MergeOperator.Merge(key, base, operand.Value)
+	if err != nil {
+		return ValueDeletable{}, err
+	}
+	operand.Value = value
+	return operand, nil
+}
 
-	<-w.activeTable.isDurableCh
+// validate returns an error if the entry cannot be encoded in a WAL SSTable
+func (w *WAL) validate(e batchEntry) error {
+	if len(e.key) == 0 {
+		return ErrEmptyKey
+	}
+	if len(e.key) > math.MaxUint16 {
+		return ErrKeyTooLarge
+	}
+	if uint64(len(e.value.Value)) >= types.Tombstone {
+		return ErrValueTooLarge
+	}
+	if e.value.IsMerge && w.conf.MergeOperator == nil {
+		return ErrNoMergeOperator
+	}
 	return nil
 }
 
+// isStalled returns true if unflushed writes exceed the limits in
+// Config which stall writes. The caller must hold the lock.
+func (w *WAL) isStalled() bool {
+	if w.conf.MaxUnflushedTables > 0 && len(w.immutableTables) >= w.conf.MaxUnflushedTables {
+		return true
+	}
+	if w.conf.MaxUnflushedBytes > 0 {
+		size := w.activeTable.size.Load()
+		for _, t := range w.immutableTables {
+			size += t.size.Load()
+		}
+		return size >= w.conf.MaxUnflushedBytes
+	}
+	return false
+}
+
+// Get returns the most recent value of the key from the tables which have not yet been
+// handed off to Config.OnFlush. An expired value is treated as if the key was deleted.
+// Merge operands are combined with the most recent value of the key using
+// Config.MergeOperator, if the tables do not contain a value for the key, the operands
+// are combined as if the key has no value.
 func (w *WAL) Get(key []byte) ([]byte, error) {
 	w.mu.RLock()
 	defer w.mu.RUnlock()
+	if w.closed {
+		return nil, ErrClosed
+	}
+	return w.get(key)
+}
+
+// get returns the most recent value of the key, the caller must hold the lock
+func (w *WAL) get(key []byte) ([]byte, error) {
+	now := time.Now()
 
-	// Check active table first
-	if value := w.activeTable.skl.Get(key); value != nil {
-		vd := value.Value.(ValueDeletable)
-		if vd.IsDelete {
-			return nil, errors.New("key not found")
+	// Check the active table first, then the immutable tables in reverse
+	// order, collecting merge operands until a value is found.
+	var operands [][]byte
+	for i := len(w.immutableTables); i >= 0; i-- {
+		table := w.activeTable
+		if i < len(w.immutableTables) {
+			table = w.immutableTables[i]
 		}
-		return vd.Value, nil
-	}
 
-	// Check immutable tables in reverse order
-	for i := len(w.immutableTables) - 1; i >= 0; i-- {
-		if value := w.immutableTables[i].skl.Get(key); value != nil {
-			vd := value.Value.(ValueDeletable)
-			if vd.IsDelete {
+		vd, ok := table.Get(key)
+		if !ok {
+			continue
+		}
+		if vd.IsDelete || vd.IsExpired(now) {
+			if len(operands) == 0 {
 				return nil, errors.New("key not found")
 			}
-			return vd.Value, nil
+			return resolve(w.conf.MergeOperator, key, nil, operands)
+		}
+		if !vd.IsMerge {
+			return resolve(w.conf.MergeOperator, key, vd.Value, operands)
 		}
+		operands = append(operands, vd.Value)
+	}
+
+	if len(operands) == 0 {
+		return nil, errors.New("key not found")
+	}
+	return resolve(w.conf.MergeOperator, key, nil, operands)
+}
+
+// resolve applies the merge operands, ordered newest first, to the value
+func resolve(op MergeOperator, key []byte, value []byte, operands [][]byte) ([]byte, error) {
+	if op == nil && len(operands) != 0 {
+		return nil, fmt.Errorf("while merging key '%s': %w", key, ErrNoMergeOperator)
 	}
+	for i := len(operands) - 1; i >= 0; i-- {
+		var err error
+		if value, err = op.Merge(key, value, operands[i]); err != nil {
+			return nil, fmt.Errorf("while merging key '%s': %w", key, err)
+		}
+	}
+	return value, nil
+}
+
+// NextId returns the id which will be assigned to the next WAL SSTable flushed to the store
+func (w *WAL) NextId() uint64 {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.nextId
+}
+
+// LastId returns the id of the most recent WAL SSTable written to the store, or zero if
+// no WAL SSTable has been written. This is the `wal_id_last_seen` recorded in the manifest.
+func (w *WAL) LastId() uint64 {
+	return w.lastId.Load()
+}
 
-	return nil, errors.New("key not found")
+// Epoch returns the writer epoch claimed by the WAL when it was created
+func (w *WAL) Epoch() uint64 {
+	return w.epoch
+}
+
+// LastSeq returns the sequence number assigned to the most recent write, including writes
+// recovered from the object store, or zero if nothing has been written.
+func (w *WAL) LastSeq() uint64 {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.lastSeq
 }
 
 func (w *WAL) periodicFlush() {
-	ticker := time.NewTicker(w.flushInterval)
+	ticker := time.NewTicker(w.conf.FlushInterval)
 	defer ticker.Stop()
 
 	for {
@@ -129,54 +642,216 @@ func (w *WAL) periodicFlush() {
 func (w *WAL) flushActiveTable() {
 	w.mu.Lock()
 	defer w.mu.Unlock()
+	w.rotateActiveTable()
+}
+
+// rotateActiveTable replaces the active table with a new table and flushes
+// the previously active table to the object store. The caller must hold the lock.
+func (w *WAL) rotateActiveTable() {
+	// Nothing has been written since the last flush
+	if w.activeTable.skl.Len() == 0 {
+		return
+	}
 
 	// Create a new active table
 	newActiveTable := newKVTable()
 
 	// Move the current active table to immutable tables
 	immutableTable := w.activeTable
+	immutableTable.id = w.nextId
+	if len(w.immutableTables) != 0 {
+		immutableTable.prev = w.immutableTables[len(w.immutableTables)-1]
+	}
+	w.nextId++
 	w.immutableTables = append(w.immutableTables, immutableTable)
 	w.activeTable = newActiveTable
 
 	// Start a goroutine to flush the immutable table to object store
-	go w.flushTableToObjectStore(immutableTable)
+	w.flushWg.Add(1)
+	go func() {
+		defer w.flushWg.Done()
+		w.flushTableToObjectStore(immutableTable)
+	}()
 }
 
 func (w *WAL) flushTableToObjectStore(table *KVTable) {
-	// Implement the logic to serialize the table and write it to the object store
-	serializedData := serializeKVTable(table)
-	err := w.store.Write(serializedData)
-	if err != nil {
-		// Handle error (you might want to implement a retry mechanism)
+	// Tables are written in id order, else a crash could result in recovering a
+	// table without the writes from a previous table which was never written.
+	if table.prev != nil {
+		if err := table.prev.awaitDurable(context.Background()); err != nil {
+			table.markDurable(err)
+			return
+		}
+		table.prev = nil
+	}
+
+	// Nothing to write, the table is trivially durable
+	if table.skl.Len() == 0 {
+		table.markDurable(nil)
+		return
+	}
+
+	// Once a flush has failed, later tables must not be written, else
+	// recovery would replay them without the writes in the failed table.
+	if err := w.readOnlyErr(); err != nil {
+		table.markDurable(err)
 		return
 	}
 
-	err = w.store.Sync()
+	serializedData, err := serializeKVTable(table, w.conf.SSTable, w.epoch)
 	if err != nil {
-		// Handle error
+		w.failed(table, err)
 		return
 	}
 
+	backoff := w.conf.FlushBackoff
+	for attempt := 0; ; attempt++ {
+		err = w.writeTable(table.id, serializedData)
+		if err == nil {
+			break
+		}
+		if attempt >= w.conf.FlushRetries || errors.Is(err, ErrFenced) {
+			w.failed(table, err)
+			return
+		}
+
+		// Stop retrying once the WAL is closed, such that Close does not wait on the backoff
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-w.stopCh:
+			timer.Stop()
+			w.failed(table, err)
+			return
+		}
+		backoff *= 2
+	}
+
+	w.lastId.Store(table.id)
+	w.handoff(table)
+
 	// Notify waiting clients that the table is durable
-	close(table.isDurableCh)
+	table.markDurable(nil)
+}
+
+// handoff passes the durable table to Config.OnFlush, then removes the table from the
+// immutable tables and wakes any stalled writers. Since each table waits for the previous
+// table to become durable before it is written, tables are handed off in id order.
+func (w *WAL) handoff(table *KVTable) {
+	if w.conf.OnFlush != nil {
+		w.conf.OnFlush(table)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for i, t := range w.immutableTables {
+		if t == table {
+			w.immutableTables = append(w.immutableTables[:i], w.immutableTables[i+1:]...)
+			break
+		}
+	}
+	w.notifyFlushed()
 }
 
-func serializeKVTable(table *KVTable) []byte {
-	// Implement the logic to serialize the KVTable
-	// This is a placeholder implementation
-	serialized := make([]byte, 0)
+// notifyFlushed wakes all writers stalled waiting for a flush. The caller must hold the lock.
+func (w *WAL) notifyFlushed() {
+	close(w.flushedCh)
+	w.flushedCh = make(chan struct{})
+}
+
+// writeTable writes the encoded table to the object store and syncs it. The table is only
+// written if no object exists with the same id, else ErrFenced is returned if the existing
+// object was written by another WAL.
+func (w *WAL) writeTable(id uint64, data []byte) error {
+	err := w.conf.Store.PutIfNotExists(walPath(id), data)
+	if errors.Is(err, ErrAlreadyExists) {
+		err = w.checkFenced(id)
+	}
+	if err != nil {
+		return fmt.Errorf("while writing WAL object '%s': %w", walPath(id), err)
+	}
+	if err := w.conf.Store.Sync(); err != nil {
+		return fmt.Errorf("while syncing WAL object '%s': %w", walPath(id), err)
+	}
+	return nil
+}
+
+// failed puts the WAL into the read-only state and notifies everyone waiting on the table
+func (w *WAL) failed(table *KVTable, err error) {
+	err = fmt.Errorf("%w: %w", ErrReadOnly, err)
+	w.failure.CompareAndSwap(nil, &err)
+	table.markDurable(err)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.notifyFlushed()
+}
+
+// readOnlyErr returns the error which caused the WAL to enter the read-only
+// state, returns nil if no flush has failed.
+func (w *WAL) readOnlyErr() error {
+	if err := w.failure.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
+
+// serializeKVTable encodes the KVTable as an SSTable using sstable.Builder. WAL SSTables
+// have the same format as compacted SSTables, deletes are encoded as tombstones.
+func serializeKVTable(table *KVTable, conf sstable.Config, epoch uint64) ([]byte, error) {
+	builder := sstable.NewBuilder(conf)
+	builder.SetWriterEpoch(epoch)
 	for iter := table.skl.Front(); iter != nil; iter = iter.Next() {
-		key := iter.Key().([]byte)
 		value := iter.Value.(ValueDeletable)
-		// Append key-value pair to serialized data
-		// You'll need to implement a proper serialization format
-		serialized = append(serialized, key...)
-		serialized = append(serialized, value.Value...)
+		err := builder.AddEntry(types.KeyValue{
+			Key: iter.Key().([]byte),
+			Value: types.Value{
+				Value:       value.Value,
+				IsTombstone: value.IsDelete,
+				IsMerge:     value.IsMerge,
+				ExpireAt:    value.ExpireAt,
+			},
+			Seq: value.Seq,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("while adding key to SSTable: %w", err)
+		}
 	}
-	return serialized
+
+	t := builder.Build()
+	if t == nil {
+		return nil, errors.New("while encoding SSTable: sstable.Builder.Build() failed")
+	}
+	return t.Data, nil
 }
 
-func (w *WAL) Close() {
+// Close flushes the active table and waits for all outstanding flushes to complete, returning
+// the error which caused a flush to fail, if any. Once Close is called, all writes and reads
+// return ErrClosed, including writes stalled waiting for a flush. If the context is cancelled
+// before the flushes complete, the context error is returned and the flushes continue in the
+// background.
+func (w *WAL) Close(ctx context.Context) error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return ErrClosed
+	}
+	w.closed = true
+	w.rotateActiveTable()
+	w.notifyFlushed()
+	w.mu.Unlock()
 	close(w.stopCh)
-	// Implement any necessary cleanup
+
+	done := make(chan struct{})
+	go func() {
+		w.flushWg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return w.readOnlyErr()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
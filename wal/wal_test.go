@@ -0,0 +1,726 @@
+package wal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thrawn01/lsm-go/internal/compress"
+	"github.com/thrawn01/lsm-go/internal/sstable"
+	"github.com/thrawn01/lsm-go/internal/sstable/types"
+	"github.com/thrawn01/lsm-go/objstore"
+)
+
+type mockStore struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	names   []string
+	synced  int
+	// lists is the number of calls to List()
+	lists int
+
+	// writeErrs are returned by successive calls to Put() until exhausted
+	writeErrs []error
+	// syncErr if not nil is returned by every call to Sync()
+	syncErr error
+	// blockWrites if not nil blocks calls to Put() until closed
+	blockWrites chan struct{}
+}
+
+func newMockStore() *mockStore {
+	return &mockStore{objects: make(map[string][]byte)}
+}
+
+func (m *mockStore) Put(path string, data []byte) error {
+	return m.put(path, data, false)
+}
+
+func (m *mockStore) PutIfNotExists(path string, data []byte) error {
+	return m.put(path, data, true)
+}
+
+func (m *mockStore) put(path string, data []byte, ifNotExists bool) error {
+	if m.blockWrites != nil {
+		<-m.blockWrites
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.writeErrs) != 0 {
+		err := m.writeErrs[0]
+		m.writeErrs = m.writeErrs[1:]
+		return err
+	}
+	if _, ok := m.objects[path]; ok && ifNotExists {
+		return ErrAlreadyExists
+	}
+	if _, ok := m.objects[path]; !ok {
+		m.names = append(m.names, path)
+	}
+	m.objects[path] = data
+	return nil
+}
+
+func (m *mockStore) Get(path string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects[path]
+	if !ok {
+		return nil, fmt.Errorf("%w: '%s'", objstore.ErrNotFound, path)
+	}
+	return data, nil
+}
+
+func (m *mockStore) GetRange(path string, r objstore.Range) ([]byte, error) {
+	data, err := m.Get(path)
+	if err != nil {
+		return nil, err
+	}
+	if r.Start > r.End || r.End > uint64(len(data)) {
+		return nil, fmt.Errorf("%w: [%d, %d) of '%s'", objstore.ErrInvalidRange, r.Start, r.End, path)
+	}
+	return data[r.Start:r.End], nil
+}
+
+func (m *mockStore) Head(path string) (objstore.ObjectInfo, error) {
+	data, err := m.Get(path)
+	if err != nil {
+		return objstore.ObjectInfo{}, err
+	}
+	return objstore.ObjectInfo{Size: uint64(len(data))}, nil
+}
+
+func (m *mockStore) List(prefix string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lists++
+	var paths []string
+	for _, name := range m.names {
+		if strings.HasPrefix(name, prefix) {
+			paths = append(paths, name)
+		}
+	}
+	return paths, nil
+}
+
+func (m *mockStore) Delete(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, path)
+	for i, name := range m.names {
+		if name == path {
+			m.names = append(m.names[:i], m.names[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (m *mockStore) Sync() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.syncErr != nil {
+		return m.syncErr
+	}
+	m.synced++
+	return nil
+}
+
+// discard is a Config.OnFlush which drops the flushed tables
+func discard(*KVTable) {}
+
+var testSSTableConfig = sstable.Config{
+	BlockSize:        30,
+	MinFilterKeys:    2,
+	FilterBitsPerKey: 10,
+	Compression:      compress.CodecNone,
+}
+
+// readEntries decodes every entry from the encoded SSTable
+func readEntries(t *testing.T, conf sstable.Config, data []byte) []types.KeyValue {
+	t.Helper()
+	entries, _, err := decodeEntries("test", data, conf)
+	require.NoError(t, err)
+	return entries
+}
+
+func TestFlushTableToObjectStore(t *testing.T) {
+	store := newMockStore()
+	w := &WAL{conf: Config{Store: store, SSTable: testSSTableConfig}, flushedCh: make(chan struct{})}
+
+	table := newKVTable()
+	table.id = 1
+	table.skl.Set([]byte("key1"), ValueDeletable{Value: []byte("value1")})
+	table.skl.Set([]byte("key2"), ValueDeletable{IsDelete: true})
+	table.skl.Set([]byte("key3"), ValueDeletable{Value: []byte("")})
+	table.skl.Set([]byte("key4"), ValueDeletable{Value: []byte("value4")})
+
+	w.flushTableToObjectStore(table)
+	<-table.isDurableCh
+
+	require.Equal(t, []string{walPath(1)}, store.names)
+	assert.Equal(t, 1, store.synced)
+	assert.Equal(t, uint64(1), w.LastId())
+
+	entries := readEntries(t, testSSTableConfig, store.objects[walPath(1)])
+	assert.Equal(t, []types.KeyValue{
+		{Key: []byte("key1"), Value: types.Value{Value: []byte("value1")}},
+		{Key: []byte("key2"), Value: types.Value{IsTombstone: true}},
+		{Key: []byte("key3"), Value: types.Value{Value: []byte("")}},
+		{Key: []byte("key4"), Value: types.Value{Value: []byte("value4")}},
+	}, entries)
+}
+
+func TestFlushEmptyTable(t *testing.T) {
+	store := newMockStore()
+	w := &WAL{conf: Config{Store: store, SSTable: testSSTableConfig}, flushedCh: make(chan struct{})}
+
+	table := newKVTable()
+	w.flushTableToObjectStore(table)
+	<-table.isDurableCh
+
+	assert.Equal(t, 0, len(store.names))
+}
+
+func TestSerializeKVTable(t *testing.T) {
+	for _, codec := range []compress.Codec{compress.CodecNone, compress.CodecSnappy, compress.CodecZstd} {
+		t.Run(codec.String(), func(t *testing.T) {
+			conf := testSSTableConfig
+			conf.Compression = codec
+
+			table := newKVTable()
+			table.skl.Set([]byte("a"), ValueDeletable{Value: []byte("value-a")})
+			table.skl.Set([]byte("b"), ValueDeletable{IsDelete: true})
+			table.skl.Set([]byte("c"), ValueDeletable{Value: []byte("value-c")})
+
+			data, err := serializeKVTable(table, conf, 0)
+			require.NoError(t, err)
+
+			blob := &bytesBlob{id: "test", data: data}
+			decoder := &sstable.Decoder{Config: conf}
+			info, err := decoder.ReadInfo(blob)
+			require.NoError(t, err)
+			assert.Equal(t, []byte("a"), info.FirstKey)
+			assert.Equal(t, codec, info.CompressionCodec)
+
+			// The entries should span multiple blocks as they exceed the block size
+			index, err := decoder.ReadIndex(info, blob)
+			require.NoError(t, err)
+			assert.Greater(t, len(index.AsFlatBuf().BlockMeta), 1)
+
+			bloomFilter, err := decoder.ReadBloom(info, blob)
+			require.NoError(t, err)
+			require.NotNil(t, bloomFilter)
+			assert.True(t, bloomFilter.HasKey([]byte("b")))
+
+			entries := readEntries(t, conf, data)
+			assert.Equal(t, []types.KeyValue{
+				{Key: []byte("a"), Value: types.Value{Value: []byte("value-a")}},
+				{Key: []byte("b"), Value: types.Value{IsTombstone: true}},
+				{Key: []byte("c"), Value: types.Value{Value: []byte("value-c")}},
+			}, entries)
+		})
+	}
+}
+
+func TestFlushRetry(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	errStore := errors.New("store unavailable")
+	store.writeErrs = []error{errStore, errStore}
+	w := &WAL{conf: Config{
+		Store:        store,
+		SSTable:      testSSTableConfig,
+		FlushRetries: 2,
+		FlushBackoff: time.Millisecond,
+	}, flushedCh: make(chan struct{})}
+
+	table := newKVTable()
+	table.id = 1
+	table.skl.Set([]byte("key1"), ValueDeletable{Value: []byte("value1")})
+
+	w.flushTableToObjectStore(table)
+	require.NoError(t, table.awaitDurable(ctx))
+	assert.Equal(t, []string{walPath(1)}, store.names)
+	assert.NoError(t, w.readOnlyErr())
+}
+
+func TestFlushRetriesDisabled(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	errStore := errors.New("store unavailable")
+	w, err := NewWAL(Config{
+		Store:         store,
+		FlushInterval: time.Hour,
+		SSTable:       testSSTableConfig,
+		FlushRetries:  -1,
+		FlushBackoff:  time.Millisecond,
+		OnFlush:       discard,
+	})
+	require.NoError(t, err)
+	defer func() { _ = w.Close(context.Background()) }()
+	assert.Equal(t, 0, w.conf.FlushRetries)
+
+	// The first failed write is not retried
+	store.mu.Lock()
+	store.writeErrs = []error{errStore}
+	store.mu.Unlock()
+	done := make(chan error)
+	go func() {
+		done <- w.Put(ctx, []byte("key1"), []byte("value1"), Options{AwaitFlush: true})
+	}()
+	require.Eventually(t, func() bool {
+		_, err := w.Get([]byte("key1"))
+		return err == nil
+	}, 5*time.Second, time.Millisecond)
+	w.flushActiveTable()
+	err = <-done
+	assert.ErrorIs(t, err, ErrReadOnly)
+	assert.ErrorIs(t, err, errStore)
+}
+
+func TestFlushFailure(t *testing.T) {
+	ctx := context.Background()
+	errStore := errors.New("store unavailable")
+
+	for _, tt := range []struct {
+		name      string
+		writeErrs []error
+		syncErr   error
+	}{
+		{name: "Write", writeErrs: []error{errStore, errStore, errStore}},
+		{name: "Sync", syncErr: errStore},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMockStore()
+			w, err := NewWAL(Config{
+				Store:         store,
+				FlushInterval: time.Hour,
+				SSTable:       testSSTableConfig,
+				FlushRetries:  2,
+				FlushBackoff:  time.Millisecond,
+				OnFlush:       discard,
+			})
+			require.NoError(t, err)
+			defer func() { _ = w.Close(context.Background()) }()
+			store.writeErrs = tt.writeErrs
+			store.syncErr = tt.syncErr
+
+			w.mu.Lock()
+			table := w.activeTable
+			table.skl.Set([]byte("key1"), ValueDeletable{Value: []byte("value1")})
+			w.mu.Unlock()
+			w.flushActiveTable()
+
+			// Waiters receive the error which caused the flush to fail
+			err = table.awaitDurable(ctx)
+			require.Error(t, err)
+			assert.ErrorIs(t, err, ErrReadOnly)
+			assert.ErrorIs(t, err, errStore)
+
+			// Subsequent writes are rejected
+			err = w.Put(ctx, []byte("key2"), []byte("value2"), Options{})
+			assert.ErrorIs(t, err, ErrReadOnly)
+			assert.ErrorIs(t, err, errStore)
+			err = w.Delete(ctx, []byte("key1"))
+			assert.ErrorIs(t, err, ErrReadOnly)
+
+			// Tables flushed after the failure are not written
+			next := newKVTable()
+			next.id = 3
+			next.skl.Set([]byte("key3"), ValueDeletable{Value: []byte("value3")})
+			w.flushTableToObjectStore(next)
+			assert.ErrorIs(t, next.awaitDurable(ctx), ErrReadOnly)
+			store.mu.Lock()
+			assert.NotContains(t, store.names, walPath(3))
+			store.mu.Unlock()
+			assert.Equal(t, uint64(1), w.LastId())
+		})
+	}
+}
+
+func TestFlushFaults(t *testing.T) {
+	ctx := context.Background()
+	errStore := errors.New("store unavailable")
+
+	for _, tt := range []struct {
+		name   string
+		faults []objstore.Fault
+		// fails is true if the flush is expected to fail
+		fails bool
+	}{
+		{
+			name: "SlowWriteError",
+			faults: []objstore.Fault{
+				{Op: objstore.OpWrite, Path: walPath(2), Count: 2, Latency: 10 * time.Millisecond, Err: errStore},
+			},
+		},
+		{
+			// The retry finds the object written by the first attempt
+			name:   "SyncError",
+			faults: []objstore.Fault{{Op: objstore.OpSync, Count: 1, Err: errStore}},
+		},
+		{
+			// A partially written object is never mistaken for the table
+			name:   "PartialWrite",
+			faults: []objstore.Fault{{Op: objstore.OpWrite, Path: walPath(2), Count: 1, PartialWrite: 10}},
+			fails:  true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			store := objstore.NewMemoryStore()
+			w, err := NewWAL(Config{
+				Store:         store,
+				FlushInterval: time.Hour,
+				SSTable:       testSSTableConfig,
+				FlushRetries:  2,
+				FlushBackoff:  time.Millisecond,
+				OnFlush:       discard,
+			})
+			require.NoError(t, err)
+			defer func() { _ = w.Close(context.Background()) }()
+			for _, f := range tt.faults {
+				store.Inject(f)
+			}
+
+			require.NoError(t, w.Put(ctx, []byte("key1"), []byte("value1"), Options{}))
+			w.mu.RLock()
+			table := w.activeTable
+			w.mu.RUnlock()
+			w.flushActiveTable()
+
+			err = table.awaitDurable(ctx)
+			if tt.fails {
+				assert.ErrorIs(t, err, ErrReadOnly)
+				assert.Equal(t, uint64(1), w.LastId())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, uint64(2), w.LastId())
+
+			data, err := store.Get(walPath(2))
+			require.NoError(t, err)
+			entries := readEntries(t, testSSTableConfig, data)
+			require.Len(t, entries, 1)
+			assert.Equal(t, []byte("value1"), entries[0].Value.Value)
+		})
+	}
+}
+
+func TestGroupCommit(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	w, err := NewWAL(Config{Store: store, FlushInterval: time.Hour, SSTable: testSSTableConfig, OnFlush: discard})
+	require.NoError(t, err)
+	defer func() { _ = w.Close(context.Background()) }()
+
+	const writers = 50
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		go func(i int) {
+			errs <- w.Put(ctx, []byte(fmt.Sprintf("key-%03d", i)), []byte("value"), Options{AwaitFlush: true})
+		}(i)
+	}
+
+	// Wait for every writer to enqueue into the active table without holding the lock
+	require.Eventually(t, func() bool {
+		w.mu.RLock()
+		defer w.mu.RUnlock()
+		return w.activeTable.skl.Len() == writers
+	}, 5*time.Second, time.Millisecond)
+
+	// A single flush makes every write durable
+	w.flushActiveTable()
+	for i := 0; i < writers; i++ {
+		select {
+		case err := <-errs:
+			require.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timed out waiting for writers")
+		}
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	assert.Equal(t, []string{walPath(1), walPath(2)}, store.names)
+	assert.Equal(t, writers, len(readEntries(t, testSSTableConfig, store.objects[walPath(2)])))
+}
+
+func TestAwaitFlushPeriodic(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	w, err := NewWAL(Config{Store: store, FlushInterval: 10 * time.Millisecond, SSTable: testSSTableConfig, OnFlush: discard})
+	require.NoError(t, err)
+	defer func() { _ = w.Close(context.Background()) }()
+
+	done := make(chan error)
+	go func() {
+		if err := w.Put(ctx, []byte("key1"), []byte("value1"), Options{AwaitFlush: true}); err != nil {
+			done <- err
+			return
+		}
+		done <- w.Delete(ctx, []byte("key1"))
+	}()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "periodic flush did not complete while writers were waiting")
+	}
+	assert.Equal(t, uint64(3), w.LastId())
+}
+
+func TestZeroConfig(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	w, err := NewWAL(Config{Store: store, OnFlush: discard})
+	require.NoError(t, err)
+	defer func() { _ = w.Close(context.Background()) }()
+
+	assert.Equal(t, 100*time.Millisecond, w.conf.FlushInterval)
+	assert.Equal(t, 4096, w.conf.SSTable.BlockSize)
+	assert.Equal(t, 10, w.conf.SSTable.FilterBitsPerKey)
+
+	// The periodic flush encodes the table using the default config
+	require.NoError(t, w.Put(ctx, []byte("key1"), []byte("value1"), Options{AwaitFlush: true}))
+	store.mu.Lock()
+	data := store.objects[walPath(w.LastId())]
+	store.mu.Unlock()
+	entries := readEntries(t, w.conf.SSTable, data)
+	require.Len(t, entries, 1)
+	assert.Equal(t, []byte("key1"), entries[0].Key)
+	assert.Equal(t, []byte("value1"), entries[0].Value.Value)
+}
+
+func TestFlushOnMaxTableSize(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	w, err := NewWAL(Config{
+		Store:         store,
+		FlushInterval: time.Hour,
+		SSTable:       testSSTableConfig,
+		MaxTableSize:  100,
+		OnFlush:       discard,
+	})
+	require.NoError(t, err)
+	defer func() { _ = w.Close(context.Background()) }()
+
+	// Each write is 20 bytes, so every 5 writes should produce a WAL SST
+	for i := 0; i < 20; i++ {
+		key := []byte(fmt.Sprintf("key-%06d", i))
+		require.NoError(t, w.Put(ctx, key, []byte("value-1234"), Options{}))
+	}
+
+	require.Eventually(t, func() bool { return w.LastId() == 5 }, 5*time.Second, time.Millisecond)
+
+	// Tables are written in id order after the fence
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	require.Equal(t, []string{walPath(1), walPath(2), walPath(3), walPath(4), walPath(5)}, store.names)
+	for _, name := range store.names[1:] {
+		assert.Equal(t, 5, len(readEntries(t, testSSTableConfig, store.objects[name])))
+	}
+	assert.Equal(t, 0, w.activeTable.skl.Len())
+}
+
+func TestMaxTableSizeDoesNotSplitBatch(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	w, err := NewWAL(Config{
+		Store:         store,
+		FlushInterval: time.Hour,
+		SSTable:       testSSTableConfig,
+		MaxTableSize:  50,
+		OnFlush:       discard,
+	})
+	require.NoError(t, err)
+	defer func() { _ = w.Close(context.Background()) }()
+
+	var b WriteBatch
+	for i := 0; i < 10; i++ {
+		b.Put([]byte(fmt.Sprintf("key-%05d", i)), []byte("value-1234"))
+	}
+	require.NoError(t, w.Write(ctx, &b, Options{AwaitFlush: true}))
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	require.Equal(t, []string{walPath(1), walPath(2)}, store.names)
+	assert.Equal(t, 10, len(readEntries(t, testSSTableConfig, store.objects[walPath(2)])))
+}
+
+func TestWriteStallUnflushedTables(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	w, err := NewWAL(Config{
+		Store:              store,
+		FlushInterval:      time.Hour,
+		SSTable:            testSSTableConfig,
+		MaxUnflushedTables: 1,
+		OnFlush:            discard,
+	})
+	require.NoError(t, err)
+	defer func() { _ = w.Close(context.Background()) }()
+	store.blockWrites = make(chan struct{})
+
+	require.NoError(t, w.Put(ctx, []byte("key1"), []byte("value1"), Options{}))
+	w.flushActiveTable()
+
+	// The flush is blocked in the store, so writes stall until the context is cancelled
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	err = w.Put(timeout, []byte("key2"), []byte("value2"), Options{})
+	assert.ErrorIs(t, err, ErrWriteStall)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	done := make(chan error)
+	go func() {
+		done <- w.Put(ctx, []byte("key2"), []byte("value2"), Options{})
+	}()
+
+	select {
+	case err := <-done:
+		require.FailNow(t, "write should be stalled", "err: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Once the flush completes the stalled write proceeds
+	close(store.blockWrites)
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for stalled write")
+	}
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	assert.Equal(t, 0, len(w.immutableTables))
+}
+
+func TestWriteStallUnflushedBytes(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	w, err := NewWAL(Config{
+		Store:             store,
+		FlushInterval:     time.Hour,
+		SSTable:           testSSTableConfig,
+		MaxUnflushedBytes: 20,
+		OnFlush:           discard,
+	})
+	require.NoError(t, err)
+	defer func() { _ = w.Close(context.Background()) }()
+	store.blockWrites = make(chan struct{})
+
+	// 20 bytes fills the active table to the limit
+	require.NoError(t, w.Put(ctx, []byte("key-000000"), []byte("value-1234"), Options{}))
+
+	// The stalled write flushes the active table, but the flush is blocked in the store
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	err = w.Put(timeout, []byte("key-000001"), []byte("value-1234"), Options{})
+	assert.ErrorIs(t, err, ErrWriteStall)
+
+	w.mu.RLock()
+	assert.Equal(t, 1, len(w.immutableTables))
+	assert.Equal(t, 0, w.activeTable.skl.Len())
+	w.mu.RUnlock()
+
+	close(store.blockWrites)
+	require.NoError(t, w.Put(ctx, []byte("key-000001"), []byte("value-1234"), Options{}))
+	assert.Equal(t, uint64(2), w.LastId())
+}
+
+func TestWriteStallReadOnly(t *testing.T) {
+	ctx := context.Background()
+	errStore := errors.New("store unavailable")
+	store := newMockStore()
+	w, err := NewWAL(Config{
+		Store:              store,
+		FlushInterval:      time.Hour,
+		SSTable:            testSSTableConfig,
+		MaxUnflushedTables: 1,
+		FlushRetries:       1,
+		FlushBackoff:       time.Millisecond,
+		OnFlush:            discard,
+	})
+	require.NoError(t, err)
+	defer func() { _ = w.Close(context.Background()) }()
+	store.syncErr = errStore
+	store.blockWrites = make(chan struct{})
+
+	require.NoError(t, w.Put(ctx, []byte("key1"), []byte("value1"), Options{}))
+	w.flushActiveTable()
+
+	done := make(chan error)
+	go func() {
+		done <- w.Put(ctx, []byte("key2"), []byte("value2"), Options{})
+	}()
+
+	// Stalled writers are woken with the error when the flush fails
+	close(store.blockWrites)
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, ErrReadOnly)
+		assert.ErrorIs(t, err, errStore)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for stalled write")
+	}
+}
+
+func TestFlushedTablesHandedOff(t *testing.T) {
+	ctx := context.Background()
+	var tables tableCollector
+	w, err := NewWAL(Config{
+		Store:         newMockStore(),
+		FlushInterval: time.Hour,
+		SSTable:       testSSTableConfig,
+		OnFlush:       tables.OnFlush,
+	})
+	require.NoError(t, err)
+	defer func() { _ = w.Close(context.Background()) }()
+
+	var b WriteBatch
+	b.Put([]byte("key1"), []byte("value1"))
+	b.Delete([]byte("key2"))
+	done := make(chan error)
+	go func() {
+		done <- w.Write(ctx, &b, Options{AwaitFlush: true})
+	}()
+
+	require.Eventually(t, func() bool {
+		_, err := w.Get([]byte("key1"))
+		return err == nil
+	}, 5*time.Second, time.Millisecond)
+	w.flushActiveTable()
+	require.NoError(t, <-done)
+
+	// The table was handed off before the write was acknowledged
+	assert.Equal(t, []uint64{2}, tables.Ids())
+	v, ok := tables.Get([]byte("key1"))
+	require.True(t, ok)
+	assert.Equal(t, []byte("value1"), v.Value)
+	v, ok = tables.Get([]byte("key2"))
+	require.True(t, ok)
+	assert.True(t, v.IsDelete)
+
+	// Once handed off, the table is removed from the WAL
+	_, err = w.Get([]byte("key1"))
+	assert.Error(t, err)
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	assert.Equal(t, 0, len(w.immutableTables))
+}
+
+func TestNewWALRequiresOnFlush(t *testing.T) {
+	store := newMockStore()
+	_, err := NewWAL(Config{Store: store, FlushInterval: time.Hour, SSTable: testSSTableConfig})
+	assert.ErrorIs(t, err, ErrNoOnFlush)
+	// Nothing is recovered or fenced
+	assert.Empty(t, store.names)
+}
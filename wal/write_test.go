@@ -0,0 +1,514 @@
+package wal
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type op struct {
+	put    bool
+	delete bool
+	flush  bool
+	key    string
+	value  string
+}
+
+func put(k, v string) op     { return op{put: true, key: k, value: v} }
+func del(k string) op        { return op{delete: true, key: k} }
+func flush() op              { return op{flush: true} }
+func found(v string) *string { return &v }
+
+func TestWrite(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		ops  []op
+		// expected maps each key to its value, nil if the key should not be found
+		expected map[string]*string
+		// size is the expected size of the active table
+		size int64
+	}{
+		{
+			name:     "PutNewKey",
+			ops:      []op{put("key1", "value1")},
+			expected: map[string]*string{"key1": found("value1"), "key2": nil},
+			size:     10,
+		},
+		{
+			name:     "PutEmptyValue",
+			ops:      []op{put("key1", "")},
+			expected: map[string]*string{"key1": found("")},
+			size:     4,
+		},
+		{
+			name:     "OverwriteLargerValue",
+			ops:      []op{put("key1", "v1"), put("key1", "value-1")},
+			expected: map[string]*string{"key1": found("value-1")},
+			size:     11,
+		},
+		{
+			name:     "OverwriteSmallerValue",
+			ops:      []op{put("key1", "value-1"), put("key1", "v1")},
+			expected: map[string]*string{"key1": found("v1")},
+			size:     6,
+		},
+		{
+			name:     "DeleteUnseenKey",
+			ops:      []op{del("key1")},
+			expected: map[string]*string{"key1": nil},
+			size:     4,
+		},
+		{
+			name:     "DeleteExistingKey",
+			ops:      []op{put("key1", "value1"), put("key2", "value2"), del("key1")},
+			expected: map[string]*string{"key1": nil, "key2": found("value2")},
+			size:     14,
+		},
+		{
+			name:     "PutAfterDelete",
+			ops:      []op{put("key1", "value1"), del("key1"), put("key1", "value2")},
+			expected: map[string]*string{"key1": found("value2")},
+			size:     10,
+		},
+		{
+			name:     "DeleteShadowsImmutableTable",
+			ops:      []op{put("key1", "value1"), put("key2", "value2"), flush(), del("key1")},
+			expected: map[string]*string{"key1": nil, "key2": found("value2")},
+			size:     4,
+		},
+		{
+			name:     "PutShadowsImmutableTable",
+			ops:      []op{put("key1", "value1"), flush(), put("key1", "value2")},
+			expected: map[string]*string{"key1": found("value2")},
+			size:     10,
+		},
+		{
+			name:     "PutAfterDeleteInImmutableTable",
+			ops:      []op{put("key1", "value1"), del("key1"), flush(), put("key1", "value2")},
+			expected: map[string]*string{"key1": found("value2")},
+			size:     10,
+		},
+		{
+			name:     "DeleteInImmutableTable",
+			ops:      []op{put("key1", "value1"), flush(), del("key1"), flush()},
+			expected: map[string]*string{"key1": nil},
+			size:     0,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := newMockStore()
+			w, err := NewWAL(Config{Store: store, FlushInterval: time.Hour, SSTable: testSSTableConfig, OnFlush: discard})
+			require.NoError(t, err)
+			// Block writes such that flushed tables remain in the immutable tables
+			store.blockWrites = make(chan struct{})
+			defer close(store.blockWrites)
+
+			for _, o := range tt.ops {
+				switch {
+				case o.put:
+					require.NoError(t, w.Put(ctx, []byte(o.key), []byte(o.value), Options{}))
+				case o.delete:
+					// Delete waits for the flush, so write the tombstone without waiting
+					var b WriteBatch
+					b.Delete([]byte(o.key))
+					require.NoError(t, w.Write(ctx, &b, Options{}))
+				case o.flush:
+					w.flushActiveTable()
+				}
+			}
+
+			for k, expected := range tt.expected {
+				v, err := w.Get([]byte(k))
+				if expected == nil {
+					assert.Error(t, err, "key '%s'", k)
+					continue
+				}
+				require.NoError(t, err, "key '%s'", k)
+				assert.Equal(t, *expected, string(v), "key '%s'", k)
+			}
+
+			w.mu.RLock()
+			defer w.mu.RUnlock()
+			assert.Equal(t, tt.size, w.activeTable.Size())
+		})
+	}
+}
+
+func TestWriteInvalid(t *testing.T) {
+	ctx := context.Background()
+	w, err := NewWAL(Config{Store: newMockStore(), FlushInterval: time.Hour, SSTable: testSSTableConfig, OnFlush: discard})
+	require.NoError(t, err)
+	defer func() { _ = w.Close(ctx) }()
+
+	assert.ErrorIs(t, w.Put(ctx, nil, []byte("value"), Options{}), ErrEmptyKey)
+	assert.ErrorIs(t, w.Put(ctx, []byte{}, []byte("value"), Options{}), ErrEmptyKey)
+	assert.ErrorIs(t, w.Delete(ctx, nil), ErrEmptyKey)
+	assert.ErrorIs(t, w.Put(ctx, make([]byte, math.MaxUint16+1), nil, Options{}), ErrKeyTooLarge)
+
+	// An invalid write in a batch prevents the entire batch from being applied
+	var b WriteBatch
+	b.Put([]byte("key1"), []byte("value1"))
+	b.Put(nil, []byte("value2"))
+	assert.ErrorIs(t, w.Write(ctx, &b, Options{}), ErrEmptyKey)
+	_, err = w.Get([]byte("key1"))
+	assert.Error(t, err)
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	assert.Equal(t, 0, w.activeTable.skl.Len())
+	assert.Equal(t, int64(0), w.activeTable.Size())
+}
+
+func TestWriteCopiesKeyAndValue(t *testing.T) {
+	ctx := context.Background()
+	w, err := NewWAL(Config{Store: newMockStore(), FlushInterval: time.Hour, SSTable: testSSTableConfig, OnFlush: discard})
+	require.NoError(t, err)
+	defer func() { _ = w.Close(ctx) }()
+
+	key := []byte("key1")
+	value := []byte("value1")
+	require.NoError(t, w.Put(ctx, key, value, Options{}))
+	key[0] = 'X'
+	value[0] = 'X'
+
+	v, err := w.Get([]byte("key1"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value1"), v)
+	_, err = w.Get([]byte("Xey1"))
+	assert.Error(t, err)
+}
+
+func TestWriteSequenceNumbers(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	var tables tableCollector
+	conf := Config{Store: store, FlushInterval: time.Hour, SSTable: testSSTableConfig, OnFlush: tables.OnFlush}
+	w, err := NewWAL(conf)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), w.LastSeq())
+
+	require.NoError(t, w.Put(ctx, []byte("key1"), []byte("value1"), Options{}))
+	var b WriteBatch
+	b.Put([]byte("key2"), []byte("value2"))
+	b.Delete([]byte("key3"))
+	require.NoError(t, w.Write(ctx, &b, Options{}))
+	require.NoError(t, w.Put(ctx, []byte("key1"), []byte("value1-updated"), Options{}))
+	assert.Equal(t, uint64(3), w.LastSeq())
+
+	// Every write in a batch shares a sequence number, an overwrite has a new sequence number
+	expected := map[string]ValueDeletable{
+		"key1": {Value: []byte("value1-updated"), Seq: 3},
+		"key2": {Value: []byte("value2"), Seq: 2},
+		"key3": {IsDelete: true, Seq: 2},
+	}
+	w.mu.RLock()
+	for k, v := range expected {
+		actual, ok := w.activeTable.Get([]byte(k))
+		require.True(t, ok)
+		assert.Equal(t, v, actual)
+	}
+	w.mu.RUnlock()
+	require.NoError(t, w.Close(ctx))
+	require.Len(t, tables.Ids(), 1)
+	assert.Equal(t, uint64(3), tables.tables[0].MaxSeq())
+
+	// Sequence numbers are persisted in the WAL SSTable and continue after recovery
+	var recovered tableCollector
+	conf.OnFlush = recovered.OnFlush
+	r, err := NewWAL(conf)
+	require.NoError(t, err)
+	defer func() { _ = r.Close(ctx) }()
+	for k, v := range expected {
+		actual, ok := recovered.Get([]byte(k))
+		require.True(t, ok)
+		assert.Equal(t, v, actual)
+	}
+	assert.Equal(t, uint64(3), r.LastSeq())
+
+	require.NoError(t, r.Put(ctx, []byte("key4"), []byte("value4"), Options{}))
+	assert.Equal(t, uint64(4), r.LastSeq())
+}
+
+func TestWriteExpiration(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	var tables tableCollector
+	conf := Config{Store: store, FlushInterval: time.Hour, SSTable: testSSTableConfig, OnFlush: tables.OnFlush}
+	w, err := NewWAL(conf)
+	require.NoError(t, err)
+	// Block writes such that flushed tables remain in the immutable tables
+	store.blockWrites = make(chan struct{})
+
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	require.NoError(t, w.Put(ctx, []byte("key1"), []byte("value1"), Options{}))
+	w.flushActiveTable()
+	// An expired value shadows the value in the immutable table
+	require.NoError(t, w.Put(ctx, []byte("key1"), []byte("value1-expired"), Options{ExpireAt: past}))
+	require.NoError(t, w.Put(ctx, []byte("key2"), []byte("value2"), Options{ExpireAt: future}))
+	var b WriteBatch
+	b.Put([]byte("key3"), []byte("value3"))
+	b.Delete([]byte("key4"))
+	require.NoError(t, w.Write(ctx, &b, Options{ExpireAt: past}))
+
+	_, err = w.Get([]byte("key1"))
+	assert.Error(t, err)
+	v, err := w.Get([]byte("key2"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value2"), v)
+	_, err = w.Get([]byte("key3"))
+	assert.Error(t, err)
+
+	close(store.blockWrites)
+	require.NoError(t, w.Close(ctx))
+
+	// The expiration time is persisted in the WAL SSTable, a delete never expires
+	var recovered tableCollector
+	conf.OnFlush = recovered.OnFlush
+	r, err := NewWAL(conf)
+	require.NoError(t, err)
+	defer func() { _ = r.Close(ctx) }()
+	for key, expireAt := range map[string]int64{
+		"key1": past.UnixMilli(),
+		"key2": future.UnixMilli(),
+		"key3": past.UnixMilli(),
+		"key4": 0,
+	} {
+		v, ok := recovered.Get([]byte(key))
+		require.True(t, ok, "key '%s'", key)
+		assert.Equal(t, expireAt, v.ExpireAt, "key '%s'", key)
+	}
+}
+
+// appendOperator appends each operand to the existing value separated by a comma
+type appendOperator struct{}
+
+func (appendOperator) Merge(_, existing, operand []byte) ([]byte, error) {
+	if existing == nil {
+		return append([]byte(nil), operand...), nil
+	}
+	return append(append(append([]byte(nil), existing...), ','), operand...), nil
+}
+
+func TestWriteMerge(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	var tables tableCollector
+	conf := Config{Store: store, FlushInterval: time.Hour, SSTable: testSSTableConfig,
+		OnFlush: tables.OnFlush, MergeOperator: appendOperator{}}
+	w, err := NewWAL(conf)
+	require.NoError(t, err)
+	// Block writes such that flushed tables remain in the immutable tables
+	store.blockWrites = make(chan struct{})
+
+	require.NoError(t, w.Put(ctx, []byte("key1"), []byte("a"), Options{}))
+	require.NoError(t, w.Merge(ctx, []byte("key2"), []byte("a"), Options{}))
+	require.NoError(t, w.Put(ctx, []byte("key3"), []byte("a"), Options{}))
+	w.flushActiveTable()
+
+	// Operands in the active table are resolved with the value in the immutable table
+	require.NoError(t, w.Merge(ctx, []byte("key1"), []byte("b"), Options{}))
+	require.NoError(t, w.Merge(ctx, []byte("key1"), []byte("c"), Options{}))
+	require.NoError(t, w.Merge(ctx, []byte("key2"), []byte("b"), Options{}))
+	// Operands in the same batch are combined in the order they were added
+	var b WriteBatch
+	b.Delete([]byte("key3"))
+	b.Merge([]byte("key3"), []byte("b"))
+	b.Put([]byte("key4"), []byte("a"))
+	b.Merge([]byte("key4"), []byte("b"))
+	b.Merge([]byte("key5"), []byte("a"))
+	b.Merge([]byte("key5"), []byte("b"))
+	require.NoError(t, w.Write(ctx, &b, Options{}))
+
+	for key, expected := range map[string]string{
+		"key1": "a,b,c",
+		"key2": "a,b",
+		// A merge after a delete is applied as if the key has no value
+		"key3": "b",
+		"key4": "a,b",
+		"key5": "a,b",
+	} {
+		v, err := w.Get([]byte(key))
+		require.NoError(t, err, "key '%s'", key)
+		assert.Equal(t, []byte(expected), v, "key '%s'", key)
+	}
+
+	close(store.blockWrites)
+	require.NoError(t, w.Close(ctx))
+
+	// Operands which were not combined with a value in the same table are persisted as merge operands
+	var recovered tableCollector
+	conf.OnFlush = recovered.OnFlush
+	r, err := NewWAL(conf)
+	require.NoError(t, err)
+	defer func() { _ = r.Close(ctx) }()
+	for key, isMerge := range map[string]bool{
+		"key1": true,
+		"key2": true,
+		"key3": false,
+		"key4": false,
+		"key5": true,
+	} {
+		v, ok := recovered.Get([]byte(key))
+		require.True(t, ok, "key '%s'", key)
+		assert.Equal(t, isMerge, v.IsMerge, "key '%s'", key)
+	}
+}
+
+func TestWriteMergeWithoutOperator(t *testing.T) {
+	ctx := context.Background()
+	w, err := NewWAL(Config{Store: newMockStore(), FlushInterval: time.Hour, SSTable: testSSTableConfig, OnFlush: discard})
+	require.NoError(t, err)
+	defer func() { _ = w.Close(ctx) }()
+
+	assert.ErrorIs(t, w.Merge(ctx, []byte("key1"), []byte("a"), Options{}), ErrNoMergeOperator)
+	var b WriteBatch
+	b.Put([]byte("key1"), []byte("a"))
+	b.Merge([]byte("key2"), []byte("a"))
+	assert.ErrorIs(t, w.Write(ctx, &b, Options{}), ErrNoMergeOperator)
+
+	// The batch is not partially applied
+	_, err = w.Get([]byte("key1"))
+	assert.Error(t, err)
+	assert.Equal(t, uint64(0), w.LastSeq())
+
+	// An operand in the tables is never resolved without an operator
+	w.mu.Lock()
+	w.activeTable.set([]byte("key3"), ValueDeletable{Value: []byte("a"), IsMerge: true})
+	w.mu.Unlock()
+	_, err = w.Get([]byte("key3"))
+	assert.ErrorIs(t, err, ErrNoMergeOperator)
+}
+
+func TestWriteMergeExpiringValue(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		// flush is true if the table with the value is flushed before the merge is written
+		flush bool
+	}{
+		{name: "SameTable"},
+		{name: "Flushed", flush: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := newMockStore()
+			w, err := NewWAL(Config{Store: store, FlushInterval: time.Hour, SSTable: testSSTableConfig,
+				MergeOperator: appendOperator{}, OnFlush: discard})
+			require.NoError(t, err)
+			// Block writes such that flushed tables remain in the immutable tables
+			store.blockWrites = make(chan struct{})
+			defer func() {
+				close(store.blockWrites)
+				_ = w.Close(ctx)
+			}()
+
+			expireAt := time.Now().Add(500 * time.Millisecond)
+			require.NoError(t, w.Put(ctx, []byte("key1"), []byte("a"), Options{ExpireAt: expireAt}))
+			if tt.flush {
+				w.flushActiveTable()
+			}
+			require.NoError(t, w.Merge(ctx, []byte("key1"), []byte("b"), Options{}))
+
+			v, err := w.Get([]byte("key1"))
+			require.NoError(t, err)
+			assert.Equal(t, []byte("a,b"), v)
+
+			// Once the value expires, the operand is applied as if the key has no value
+			// regardless of whether the table was flushed between the writes
+			time.Sleep(time.Until(expireAt) + 10*time.Millisecond)
+			v, err = w.Get([]byte("key1"))
+			require.NoError(t, err)
+			assert.Equal(t, []byte("b"), v)
+			iter, err := w.Scan(nil, nil)
+			require.NoError(t, err)
+			assert.Equal(t, map[string]string{"key1": "b"}, scanAll(t, iter))
+		})
+	}
+}
+
+func TestWriteConcurrent(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	var tables tableCollector
+	conf := Config{
+		Store:         store,
+		FlushInterval: time.Millisecond,
+		SSTable:       testSSTableConfig,
+		MaxTableSize:  512,
+		OnFlush:       tables.OnFlush,
+	}
+	w, err := NewWAL(conf)
+	require.NoError(t, err)
+
+	const writers = 8
+	const writes = 200
+
+	// Each writer owns a distinct set of keys, such that the final value of each key is known
+	var wg sync.WaitGroup
+	expected := make([]map[string]*string, writers)
+	for i := 0; i < writers; i++ {
+		expected[i] = make(map[string]*string)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(int64(i)))
+			for n := 0; n < writes; n++ {
+				key := fmt.Sprintf("writer-%d-key-%d", i, r.Intn(20))
+				switch r.Intn(4) {
+				case 0:
+					if !assert.NoError(t, w.Delete(ctx, []byte(key))) {
+						return
+					}
+					expected[i][key] = nil
+				case 1:
+					var b WriteBatch
+					value := fmt.Sprintf("batch-%d", n)
+					b.Put([]byte(key), []byte(value))
+					if !assert.NoError(t, w.Write(ctx, &b, Options{})) {
+						return
+					}
+					expected[i][key] = &value
+				default:
+					value := fmt.Sprintf("value-%d", n)
+					if !assert.NoError(t, w.Put(ctx, []byte(key), []byte(value), Options{AwaitFlush: n%10 == 0})) {
+						return
+					}
+					expected[i][key] = &value
+				}
+				_, _ = w.Get([]byte(key))
+			}
+		}(i)
+	}
+	wg.Wait()
+	require.NoError(t, w.Close(ctx))
+
+	// Every write must be durable and recoverable in the order it was written
+	var recovered tableCollector
+	conf.OnFlush = recovered.OnFlush
+	r, err := NewWAL(conf)
+	require.NoError(t, err)
+	defer func() { _ = r.Close(ctx) }()
+
+	assert.Equal(t, tables.Ids(), recovered.Ids())
+	for i := range expected {
+		for key, value := range expected[i] {
+			v, ok := recovered.Get([]byte(key))
+			require.True(t, ok, "key '%s'", key)
+			if value == nil {
+				assert.True(t, v.IsDelete, "key '%s'", key)
+				continue
+			}
+			assert.False(t, v.IsDelete, "key '%s'", key)
+			assert.Equal(t, *value, string(v.Value), "key '%s'", key)
+		}
+	}
+}